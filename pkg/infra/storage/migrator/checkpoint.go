@@ -0,0 +1,60 @@
+package migrator
+
+import (
+	"context"
+	"time"
+
+	"xorm.io/xorm"
+)
+
+var checkpointTable = Table{
+	Name: "migration_checkpoint",
+	Columns: []*Column{
+		{Name: "migration_id", Type: DB_NVarchar, Length: 255, IsPrimaryKey: true},
+		{Name: "checkpoint", Type: DB_NVarchar, Length: 255},
+		{Name: "timestamp", Type: DB_DateTime},
+	},
+}
+
+func (mg *Migrator) ensureCheckpointTable(ctx context.Context) error {
+	_, err := mg.engine.Context(ctx).Exec(NewAddTableMigration(checkpointTable).SQL(mg.Dialect))
+	return err
+}
+
+func (mg *Migrator) getCheckpoint(sess *xorm.Session, migrationID string) (string, error) {
+	rawSQL := "SELECT " + mg.Dialect.Quote("checkpoint") +
+		" FROM " + mg.Dialect.Quote(checkpointTable.Name) +
+		" WHERE " + mg.Dialect.Quote("migration_id") + " = ?"
+
+	results, err := sess.SQL(rawSQL, migrationID).Query()
+	if err != nil {
+		return "", err
+	}
+
+	if len(results) == 0 {
+		return "", nil
+	}
+
+	return string(results[0]["checkpoint"]), nil
+}
+
+func (mg *Migrator) saveCheckpoint(sess *xorm.Session, migrationID string, checkpoint string) error {
+	if err := mg.clearCheckpoint(sess, migrationID); err != nil {
+		return err
+	}
+
+	rawSQL := "INSERT INTO " + mg.Dialect.Quote(checkpointTable.Name) +
+		" (" + mg.Dialect.Quote("migration_id") + ", " + mg.Dialect.Quote("checkpoint") + ", " + mg.Dialect.Quote("timestamp") + ")" +
+		" VALUES (?, ?, ?)"
+
+	_, err := sess.Exec(rawSQL, migrationID, checkpoint, time.Now())
+	return err
+}
+
+func (mg *Migrator) clearCheckpoint(sess *xorm.Session, migrationID string) error {
+	rawSQL := "DELETE FROM " + mg.Dialect.Quote(checkpointTable.Name) +
+		" WHERE " + mg.Dialect.Quote("migration_id") + " = ?"
+
+	_, err := sess.Exec(rawSQL, migrationID)
+	return err
+}
@@ -13,6 +13,14 @@ type Column struct {
 	IsAutoIncrement bool
 	Unique          bool
 	Default         string
+	// IsDefaultNull sets an explicit DEFAULT NULL, Default is ignored then.
+	IsDefaultNull bool
+	// Check is the expression of an inline CHECK constraint.
+	Check string
+	// UsingExpr converts existing values when UpdateTableSql changes the column type.
+	UsingExpr string
+	// IntervalPrecision is the number of fractional second digits of a DB_Interval column.
+	IntervalPrecision int
 }
 
 func (col *Column) String(d Dialect) string {
@@ -1,10 +1,24 @@
 package migrator
 
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"xorm.io/xorm"
+)
+
 type MigrationCondition interface {
 	Sql(dialect Dialect) (string, []interface{})
 	IsFulfilled(results []map[string][]byte) bool
 }
 
+// EvaluatingCondition decides by itself whether the migration runs.
+type EvaluatingCondition interface {
+	MigrationCondition
+	Evaluate(dialect Dialect, sess xorm.Interface) (bool, error)
+}
+
 type ExistsMigrationCondition struct{}
 
 func (c *ExistsMigrationCondition) IsFulfilled(results []map[string][]byte) bool {
@@ -24,7 +38,7 @@ type IfIndexExistsCondition struct {
 }
 
 func (c *IfIndexExistsCondition) Sql(dialect Dialect) (string, []interface{}) {
-	return dialect.IndexCheckSql(c.TableName, c.IndexName)
+	return dialect.IndexCheckSql(c.TableName, shortenIdentifier(c.IndexName, dialect.MaxIdentifierLength()))
 }
 
 type IfIndexNotExistsCondition struct {
@@ -34,7 +48,45 @@ type IfIndexNotExistsCondition struct {
 }
 
 func (c *IfIndexNotExistsCondition) Sql(dialect Dialect) (string, []interface{}) {
-	return dialect.IndexCheckSql(c.TableName, c.IndexName)
+	return dialect.IndexCheckSql(c.TableName, shortenIdentifier(c.IndexName, dialect.MaxIdentifierLength()))
+}
+
+type IfTableExistsCondition struct {
+	ExistsMigrationCondition
+	TableName string
+}
+
+func (c *IfTableExistsCondition) Sql(dialect Dialect) (string, []interface{}) {
+	return dialect.TableCheckSql(c.TableName)
+}
+
+type IfTableNotExistsCondition struct {
+	NotExistsMigrationCondition
+	TableName string
+}
+
+func (c *IfTableNotExistsCondition) Sql(dialect Dialect) (string, []interface{}) {
+	return dialect.TableCheckSql(c.TableName)
+}
+
+type IfConstraintExistsCondition struct {
+	ExistsMigrationCondition
+	TableName      string
+	ConstraintName string
+}
+
+func (c *IfConstraintExistsCondition) Sql(dialect Dialect) (string, []interface{}) {
+	return dialect.ConstraintCheckSql(c.TableName, c.ConstraintName)
+}
+
+type IfEnumValueNotExistsCondition struct {
+	NotExistsMigrationCondition
+	TypeName string
+	Value    string
+}
+
+func (c *IfEnumValueNotExistsCondition) Sql(dialect Dialect) (string, []interface{}) {
+	return dialect.EnumValueCheckSql(c.TypeName, c.Value)
 }
 
 type IfColumnNotExistsCondition struct {
@@ -46,3 +98,89 @@ type IfColumnNotExistsCondition struct {
 func (c *IfColumnNotExistsCondition) Sql(dialect Dialect) (string, []interface{}) {
 	return dialect.ColumnCheckSql(c.TableName, c.ColumnName)
 }
+
+type IfStatisticsNotExistsCondition struct {
+	NotExistsMigrationCondition
+	Name string
+}
+
+func (c *IfStatisticsNotExistsCondition) Sql(dialect Dialect) (string, []interface{}) {
+	return dialect.StatisticsCheckSql(c.Name)
+}
+
+// IfColumnsNotExistCondition runs the migration only if none of the columns exists.
+type IfColumnsNotExistCondition struct {
+	TableName   string
+	ColumnNames []string
+}
+
+func (c *IfColumnsNotExistCondition) Sql(dialect Dialect) (string, []interface{}) {
+	return "", nil
+}
+
+func (c *IfColumnsNotExistCondition) IsFulfilled(results []map[string][]byte) bool {
+	return true
+}
+
+func (c *IfColumnsNotExistCondition) Evaluate(dialect Dialect, sess xorm.Interface) (bool, error) {
+	existing := make([]string, 0, len(c.ColumnNames))
+	for _, name := range c.ColumnNames {
+		sql, args := dialect.ColumnCheckSql(c.TableName, name)
+		results, err := sess.SQL(sql, args...).Query()
+		if err != nil {
+			return false, err
+		}
+		if len(results) > 0 {
+			existing = append(existing, name)
+		}
+	}
+
+	switch len(existing) {
+	case 0:
+		return true, nil
+	case len(c.ColumnNames):
+		return false, nil
+	}
+	return false, fmt.Errorf("columns %s of table %s exist already, the others do not",
+		strings.Join(existing, ", "), c.TableName)
+}
+
+// AndCondition runs the migration only if all of its conditions are fulfilled.
+type AndCondition struct {
+	Conditions []MigrationCondition
+}
+
+func (c *AndCondition) Sql(dialect Dialect) (string, []interface{}) {
+	return "", nil
+}
+
+func (c *AndCondition) IsFulfilled(results []map[string][]byte) bool {
+	return true
+}
+
+func (c *AndCondition) Evaluate(dialect Dialect, sess xorm.Interface) (bool, error) {
+	for _, condition := range c.Conditions {
+		fulfilled, err := evaluateCondition(condition, dialect, sess)
+		if err != nil || !fulfilled {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// DialectCondition runs the migration only on the listed dialects.
+type DialectCondition struct {
+	Dialects []string
+}
+
+func (c *DialectCondition) Sql(dialect Dialect) (string, []interface{}) {
+	return "", nil
+}
+
+func (c *DialectCondition) IsFulfilled(results []map[string][]byte) bool {
+	return true
+}
+
+func (c *DialectCondition) Evaluate(dialect Dialect, sess xorm.Interface) (bool, error) {
+	return slices.Contains(c.Dialects, dialect.DriverName()), nil
+}
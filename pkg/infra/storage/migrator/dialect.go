@@ -1,6 +1,7 @@
 package migrator
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -16,27 +17,105 @@ type Dialect interface {
 	EqStr() string
 	ShowCreateNull() bool
 	SqlType(col *Column) string
+	// SupportEngine reports whether the dialect understands MySQL table options.
 	SupportEngine() bool
+	// TransactionalSettings reports whether a rollback undoes session settings.
+	TransactionalSettings() bool
 	LikeStr() string
 	Default(col *Column) string
 	BooleanStr(bool) string
 	DateTimeFunc(string) string
 
 	CreateIndexSql(tableName string, index *Index) string
+	CreateIndexConcurrentlySql(tableName string, index *Index) string
 	CreateTableSql(table *Table) string
+	CreateTableLikeSql(table *Table) string
 	AddColumnSql(tableName string, col *Column) string
+	AddColumnsSql(tableName string, cols []*Column) string
 	CopyTableData(sourceTable string, targetTable string, sourceCols []string, targetCols []string) string
+	BulkUpsertSql(tableName string, cols []string, conflictCols []string, updateCols []string, rowCount int) string
+	BulkInsertSql(tableName string, cols []string, rowCount int) string
+	CopyTableDataRangeSql(sourceTable string, targetTable string, sourceCols []string, targetCols []string, keyCol string) string
+	KeyRangeSql(tableName string, keyCol string) string
 	DropTable(tableName string) string
+	TruncateTablesSql(tableNames []string) string
 	DropIndexSql(tableName string, index *Index) string
 	DropColumnSql(tableName string, col *Column) string
+	DropColumnIfExistsSql(tableName string, col *Column) string
+	DropColumnsIfExistSql(tableName string, columnNames []string) string
+	SetNotNullSql(tableName string, col *Column) string
 
 	RenameColumn(tableName string, oldName string, newName string) string
 
 	RenameTable(oldName string, newName string) string
+	CreateViewSql(viewName string, definition string) string
+	DropViewSql(viewName string) string
+	RefreshMaterializedViewSql(viewName string, concurrently bool) string
+	RenameIndexSql(oldTableName string, newTableName string, index *Index) string
+	RenameSequenceSql(oldTableName string, newTableName string, columnName string) string
+	ResetSequenceSql(tableName string, columnName string) string
+	InheritTableSql(childTable string, parentTable string) string
+	NoInheritTableSql(childTable string, parentTable string) string
 	UpdateTableSql(tableName string, columns []*Column) string
-
+	AlterDatabaseCharsetSql(dbName string, charset string, collation string) string
+	AddPrimaryKeySql(tableName string, columns []string) string
+	DropPrimaryKeySql(tableName string, constraintName string) string
+	DropConstraintSql(tableName string, constraintName string, ifExists bool) string
+	RenameConstraintSql(tableName string, oldName string, newName string) string
+	DropForeignKeySql(tableName string, fkName string, ifExists bool) string
+
+	SetSearchPathSql(schemas []string, local bool) string
+	DisableTriggersSql() string
+	EnableTriggersSql() string
+	DisableForeignKeyChecksSql() string
+	EnableForeignKeyChecksSql() string
+	CreateSchemaSql(name string) string
+	CreateRoleSql(role *Role) string
+	CreateEventTriggerSql(trigger *EventTrigger) string
+	DropEventTriggerSql(name string) string
+	CreateCastSql(cast *Cast) string
+	DropCastSql(source string, target string) string
+	AddEnumValueSql(typeName string, value string, ifNotExists bool) string
+	CreateAggregateSql(aggregate *Aggregate) string
+	DropAggregateSql(name string, args []string) string
+	CreateOperatorSql(operator *Operator) string
+	DropOperatorSql(name string, leftArg string, rightArg string) string
+	CreateOperatorClassSql(opClass *OperatorClass) string
+	DropOperatorClassSql(name string, method string) string
+	CreateTextSearchConfigSql(name string, copyFrom string, parser string) string
+	AlterTextSearchMappingSql(name string, tokenTypes []string, dictionaries []string, add bool) string
+	DropTextSearchConfigSql(name string) string
+	CreatePublicationSql(name string, tables []string) string
+	DropPublicationSql(name string) string
+	AddTableToPublicationSql(publication string, table string) string
+	DropTableFromPublicationSql(publication string, table string) string
+	AlterTableOwnerSql(tableName string, owner string) string
+	SetReplicaIdentitySql(tableName string, identity ReplicaIdentity) string
+	EnableRowLevelSecuritySql(tableName string, enable bool) string
+	ForceRowLevelSecuritySql(tableName string, force bool) string
+	LockTableSql(tableName string, mode LockMode) string
+	SetTableLoggedSql(tableName string, logged bool) string
+	ClusterTableSql(tableName string, indexName string) string
+	AlterSequenceOwnerSql(sequenceName string, owner string) string
+	AlterViewOwnerSql(viewName string, owner string) string
+	CommentOnIndexSql(tableName string, indexName string, comment string) string
+	CommentOnTableSql(tableName string, comment string) string
+	DropSchemaSql(name string, cascade bool) string
+
+	CreateStatisticsSql(name string, tableName string, columns []string, kinds []string) string
+	DropStatisticsSql(name string) string
+
+	TablesSql() (string, []interface{})
+	ColumnsSql() (string, []interface{})
+	IndexesSql() (string, []interface{})
 	IndexCheckSql(tableName, indexName string) (string, []interface{})
+	StatisticsCheckSql(name string) (string, []interface{})
+	DependentViewsSql(tableName string) (string, []interface{})
+	InboundForeignKeysSql(tableName string) (string, []interface{})
 	ColumnCheckSql(tableName, columnName string) (string, []interface{})
+	TableCheckSql(tableName string) (string, []interface{})
+	ConstraintCheckSql(tableName, constraintName string) (string, []interface{})
+	EnumValueCheckSql(typeName, value string) (string, []interface{})
 
 	ColString(*Column) string
 	ColStringNoPk(*Column) string
@@ -49,21 +128,39 @@ type Dialect interface {
 
 	CleanDB() error
 	NoOpSql() string
+	MaxIdentifierLength() int
+	Version() (int, error)
+	VersionContext(ctx context.Context) (int, error)
 
 	IsUniqueConstraintViolation(err error) bool
 	IsDeadlock(err error) bool
+	IsConnectionError(err error) bool
+	IsAdminShutdown(err error) bool
+	IsQueryCanceled(err error) bool
 }
 
+// NewDialect is DialectForEngine panicking on unsupported drivers.
 func NewDialect(engine *xorm.Engine) Dialect {
+	d, err := DialectForEngine(engine)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return d
+}
+
+// DialectForEngine returns the dialect matching the driver of engine.
+func DialectForEngine(engine *xorm.Engine) (Dialect, error) {
 	name := engine.DriverName()
 	switch name {
 	case POSTGRES:
-		return NewPostgresDialect(engine)
+		return NewPostgresDialect(engine), nil
 	}
 
-	panic("Unsupported database type: " + name)
+	return nil, fmt.Errorf("unsupported database type: %s", name)
 }
 
+// BaseDialect renders MySQL flavoured SQL, dialects override what differs.
 type BaseDialect struct {
 	dialect    Dialect
 	engine     *xorm.Engine
@@ -78,6 +175,10 @@ func (b *BaseDialect) ShowCreateNull() bool {
 	return true
 }
 
+func (b *BaseDialect) TransactionalSettings() bool {
+	return false
+}
+
 func (b *BaseDialect) AndStr() string {
 	return "AND"
 }
@@ -95,6 +196,9 @@ func (b *BaseDialect) EqStr() string {
 }
 
 func (b *BaseDialect) Default(col *Column) string {
+	if col.IsDefaultNull {
+		return "NULL"
+	}
 	return col.Default
 }
 
@@ -103,9 +207,13 @@ func (db *BaseDialect) DateTimeFunc(value string) string {
 }
 
 func (b *BaseDialect) CreateTableSql(table *Table) string {
+	if table.LikeTable != "" {
+		return b.dialect.CreateTableLikeSql(table)
+	}
+
 	var sql string
 	if table.Schema != "" {
-		sql += "CREATE SCHEMA IF NOT EXISTS " + table.Schema + ";"
+		sql += "CREATE SCHEMA IF NOT EXISTS " + table.Schema + ";\n"
 		sql += "CREATE TABLE IF NOT EXISTS " + table.Name + " (\n"
 
 	} else {
@@ -143,34 +251,77 @@ func (b *BaseDialect) CreateTableSql(table *Table) string {
 		sql += "UNIQUE ( " + strings.Join(quotedCols, ",") + " ), "
 	}
 
+	for _, fk := range table.ForeignKeys {
+		sql += fk.String(table.Name, b.dialect) + ", "
+	}
+
+	for _, check := range table.CheckConstraints {
+		if check.Name != "" {
+			sql += "CONSTRAINT " + b.dialect.Quote(check.Name) + " "
+		}
+		sql += "CHECK (" + check.Expr + "), "
+	}
+
 	sql = sql[:len(sql)-2] + ")"
 	if b.dialect.SupportEngine() {
-		sql += " ENGINE=InnoDB DEFAULT CHARSET utf8mb4 COLLATE utf8mb4_unicode_ci"
+		engine := table.Engine
+		if engine == "" {
+			engine = "InnoDB"
+		}
+		sql += " ENGINE=" + engine
+		if table.RowFormat != "" {
+			sql += " ROW_FORMAT=" + table.RowFormat
+		}
+		sql += " DEFAULT CHARSET utf8mb4 COLLATE utf8mb4_unicode_ci"
+		if table.Comment != "" {
+			sql += " COMMENT=" + quoteLiteral(table.Comment)
+		}
 	}
 
-	sql += ";"
 	return sql
 }
 
+func (b *BaseDialect) CreateTableLikeSql(table *Table) string {
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s LIKE %s", b.dialect.Quote(table.Name), b.dialect.Quote(table.LikeTable))
+}
+
 func (db *BaseDialect) AddColumnSql(tableName string, col *Column) string {
 	return fmt.Sprintf("alter table %s ADD COLUMN %s", db.dialect.Quote(tableName), col.StringNoPk(db.dialect))
 }
 
+// AddColumnsSql adds the columns one statement at a time.
+func (db *BaseDialect) AddColumnsSql(tableName string, cols []*Column) string {
+	statements := make([]string, 0, len(cols))
+	for _, col := range cols {
+		statements = append(statements, db.dialect.AddColumnSql(tableName, col))
+	}
+	return joinSql(statements...)
+}
+
 func (db *BaseDialect) CreateIndexSql(tableName string, index *Index) string {
+	return db.createIndexSql(tableName, index, db.dialect.Quote)
+}
+
+func (db *BaseDialect) createIndexSql(tableName string, index *Index, colSql func(col string) string) string {
 	quote := db.dialect.Quote
 	var unique string
 	if index.Type == UniqueIndex {
 		unique = " UNIQUE"
 	}
 
-	idxName := index.XName(tableName)
+	idxName := index.XNameWithLimit(tableName, db.dialect.MaxIdentifierLength())
 
 	quotedCols := []string{}
 	for _, col := range index.Cols {
-		quotedCols = append(quotedCols, db.dialect.Quote(col))
+		quotedCols = append(quotedCols, colSql(col))
 	}
 
-	return fmt.Sprintf("CREATE%s INDEX %v ON %v (%v);", unique, quote(idxName), quote(tableName), strings.Join(quotedCols, ","))
+	return fmt.Sprintf("CREATE%s INDEX %v ON %v (%v)", unique, quote(idxName), quote(tableName), strings.Join(quotedCols, ","))
+}
+
+// CreateIndexConcurrentlySql falls back to a plain CREATE INDEX.
+func (db *BaseDialect) CreateIndexConcurrentlySql(tableName string, index *Index) string {
+	return db.dialect.CreateIndexSql(tableName, index)
 }
 
 func (db *BaseDialect) QuoteColList(cols []string) string {
@@ -190,6 +341,40 @@ func (db *BaseDialect) CopyTableData(sourceTable string, targetTable string, sou
 	return fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", quote(targetTable), targetColsSql, sourceColsSql, quote(sourceTable))
 }
 
+// BulkUpsertSql inserts rowCount rows and updates updateCols on duplicate keys.
+func (db *BaseDialect) BulkUpsertSql(tableName string, cols []string, conflictCols []string, updateCols []string, rowCount int) string {
+	quote := db.dialect.Quote
+	sql := db.dialect.BulkInsertSql(tableName, cols, rowCount)
+
+	if len(updateCols) == 0 {
+		return strings.Replace(sql, "INSERT INTO", "INSERT IGNORE INTO", 1)
+	}
+
+	updates := make([]string, 0, len(updateCols))
+	for _, col := range updateCols {
+		updates = append(updates, fmt.Sprintf("%s = VALUES(%s)", quote(col), quote(col)))
+	}
+
+	return sql + " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ")
+}
+
+// BulkInsertSql inserts rowCount rows in one statement.
+func (db *BaseDialect) BulkInsertSql(tableName string, cols []string, rowCount int) string {
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", db.dialect.Quote(tableName), db.QuoteColList(cols), rowPlaceholders(len(cols), rowCount))
+}
+
+// CopyTableDataRangeSql copies the rows with key > ? AND key <= ?.
+func (db *BaseDialect) CopyTableDataRangeSql(sourceTable string, targetTable string, sourceCols []string, targetCols []string, keyCol string) string {
+	quote := db.dialect.Quote
+	return db.dialect.CopyTableData(sourceTable, targetTable, sourceCols, targetCols) +
+		fmt.Sprintf(" WHERE %s > ? AND %s <= ?", quote(keyCol), quote(keyCol))
+}
+
+func (db *BaseDialect) KeyRangeSql(tableName string, keyCol string) string {
+	quote := db.dialect.Quote
+	return fmt.Sprintf("SELECT MIN(%s) AS min_key, MAX(%s) AS max_key FROM %s", quote(keyCol), quote(keyCol), quote(tableName))
+}
+
 func (db *BaseDialect) DropTable(tableName string) string {
 	quote := db.dialect.Quote
 	return fmt.Sprintf("DROP TABLE IF EXISTS %s", quote(tableName))
@@ -200,18 +385,334 @@ func (db *BaseDialect) RenameTable(oldName string, newName string) string {
 	return fmt.Sprintf("ALTER TABLE %s RENAME TO %s", quote(oldName), quote(newName))
 }
 
+func (db *BaseDialect) CreateViewSql(viewName string, definition string) string {
+	definition = strings.TrimSuffix(strings.TrimSpace(definition), ";")
+	return fmt.Sprintf("CREATE VIEW %s AS %s", db.dialect.Quote(viewName), definition)
+}
+
+func (db *BaseDialect) DropViewSql(viewName string) string {
+	return fmt.Sprintf("DROP VIEW IF EXISTS %s", db.dialect.Quote(viewName))
+}
+
+func (db *BaseDialect) RefreshMaterializedViewSql(viewName string, concurrently bool) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) DependentViewsSql(tableName string) (string, []interface{}) {
+	return "", nil
+}
+
+func (db *BaseDialect) InboundForeignKeysSql(tableName string) (string, []interface{}) {
+	return "", nil
+}
+
+func (db *BaseDialect) RenameIndexSql(oldTableName string, newTableName string, index *Index) string {
+	quote := db.dialect.Quote
+	idx := *index
+	maxLength := db.dialect.MaxIdentifierLength()
+	oldName, newName := idx.XNameWithLimit(oldTableName, maxLength), idx.XNameWithLimit(newTableName, maxLength)
+	if oldName == newName {
+		return ""
+	}
+
+	return fmt.Sprintf("ALTER TABLE %s RENAME INDEX %s TO %s", quote(newTableName), quote(oldName), quote(newName))
+}
+
+func (db *BaseDialect) RenameSequenceSql(oldTableName string, newTableName string, columnName string) string {
+	return ""
+}
+
+func (db *BaseDialect) InheritTableSql(childTable string, parentTable string) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) NoInheritTableSql(childTable string, parentTable string) string {
+	return db.dialect.NoOpSql()
+}
+
+// ResetSequenceSql is a no-op, auto increment follows inserted values.
+func (db *BaseDialect) ResetSequenceSql(tableName string, columnName string) string {
+	return db.dialect.NoOpSql()
+}
+
+// DropColumnIfExistsSql falls back to a plain drop.
+func (db *BaseDialect) DropColumnIfExistsSql(tableName string, col *Column) string {
+	return db.dialect.DropColumnSql(tableName, col)
+}
+
+// DropColumnsIfExistSql drops the columns one statement at a time.
+func (db *BaseDialect) DropColumnsIfExistSql(tableName string, columnNames []string) string {
+	statements := make([]string, 0, len(columnNames))
+	for _, name := range columnNames {
+		statements = append(statements, db.dialect.DropColumnIfExistsSql(tableName, &Column{Name: name}))
+	}
+	return joinSql(statements...)
+}
+
+// SetNotNullSql makes an existing column NOT NULL.
+func (db *BaseDialect) SetNotNullSql(tableName string, col *Column) string {
+	notNull := *col
+	notNull.Nullable = false
+	return fmt.Sprintf("ALTER TABLE %s MODIFY %s", db.dialect.Quote(tableName), notNull.StringNoPk(db.dialect))
+}
+
 func (db *BaseDialect) RenameColumn(tableName string, oldName string, newName string) string {
 	quote := db.dialect.Quote
 	return fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s", tableName, quote(oldName), quote(newName))
 }
 
+// ColumnCheckSql looks the column up in the current MySQL database.
 func (db *BaseDialect) ColumnCheckSql(tableName, columnName string) (string, []interface{}) {
+	args := []interface{}{tableName, columnName}
+	sql := "SELECT 1 FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?"
+	return sql, args
+}
+
+func (db *BaseDialect) AddPrimaryKeySql(tableName string, columns []string) string {
+	quote := db.dialect.Quote
+	quotedCols := make([]string, 0, len(columns))
+	for _, col := range columns {
+		quotedCols = append(quotedCols, quote(col))
+	}
+
+	return fmt.Sprintf("ALTER TABLE %s ADD PRIMARY KEY (%s)", quote(tableName), strings.Join(quotedCols, ", "))
+}
+
+// DropPrimaryKeySql ignores the constraint name.
+func (db *BaseDialect) DropPrimaryKeySql(tableName string, constraintName string) string {
+	return fmt.Sprintf("ALTER TABLE %s DROP PRIMARY KEY", db.dialect.Quote(tableName))
+}
+
+// DropConstraintSql ignores ifExists, see IfConstraintExistsCondition.
+func (db *BaseDialect) DropConstraintSql(tableName string, constraintName string, ifExists bool) string {
+	return fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", db.dialect.Quote(tableName), db.dialect.Quote(constraintName))
+}
+
+// RenameConstraintSql is a no-op, MySQL names keys after their columns.
+func (db *BaseDialect) RenameConstraintSql(tableName string, oldName string, newName string) string {
+	return db.dialect.NoOpSql()
+}
+
+// DropForeignKeySql ignores ifExists like DropConstraintSql.
+func (db *BaseDialect) DropForeignKeySql(tableName string, fkName string, ifExists bool) string {
+	return fmt.Sprintf("ALTER TABLE %s DROP FOREIGN KEY %s", db.dialect.Quote(tableName), db.dialect.Quote(fkName))
+}
+
+// DisableTriggersSql suspends foreign key checks, MySQL cannot switch off triggers.
+func (db *BaseDialect) DisableTriggersSql() string {
+	return "SET FOREIGN_KEY_CHECKS = 0"
+}
+
+func (db *BaseDialect) EnableTriggersSql() string {
+	return "SET FOREIGN_KEY_CHECKS = 1"
+}
+
+func (db *BaseDialect) DisableForeignKeyChecksSql() string {
+	return "SET FOREIGN_KEY_CHECKS = 0"
+}
+
+func (db *BaseDialect) EnableForeignKeyChecksSql() string {
+	return "SET FOREIGN_KEY_CHECKS = 1"
+}
+
+func (db *BaseDialect) SetSearchPathSql(schemas []string, local bool) string {
+	return db.dialect.NoOpSql()
+}
+
+// CreateSchemaSql creates a database, which is what a schema is on MySQL.
+func (db *BaseDialect) CreateSchemaSql(name string) string {
+	return fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", db.dialect.Quote(name))
+}
+
+// DropSchemaSql drops a database, which always takes its tables with it.
+func (db *BaseDialect) DropSchemaSql(name string, cascade bool) string {
+	return fmt.Sprintf("DROP DATABASE IF EXISTS %s", db.dialect.Quote(name))
+}
+
+func (db *BaseDialect) CommentOnIndexSql(tableName string, indexName string, comment string) string {
+	return db.dialect.NoOpSql()
+}
+
+// CommentOnTableSql is a no-op, CreateTableSql renders the comment inline.
+func (db *BaseDialect) CommentOnTableSql(tableName string, comment string) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) TableCheckSql(tableName string) (string, []interface{}) {
+	args := []interface{}{tableName}
+	sql := "SELECT 1 FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?"
+	return sql, args
+}
+
+func (db *BaseDialect) ConstraintCheckSql(tableName, constraintName string) (string, []interface{}) {
+	args := []interface{}{tableName, constraintName}
+	sql := "SELECT 1 FROM information_schema.TABLE_CONSTRAINTS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND CONSTRAINT_NAME = ?"
+	return sql, args
+}
+
+// EnumValueCheckSql returns no SQL, there are no enum types to check.
+func (db *BaseDialect) EnumValueCheckSql(typeName, value string) (string, []interface{}) {
+	return "", nil
+}
+
+// TablesSql lists the tables of the current database, selected as tablename.
+func (db *BaseDialect) TablesSql() (string, []interface{}) {
+	return "SELECT table_name AS tablename FROM information_schema.tables WHERE table_schema = DATABASE() AND table_type = 'BASE TABLE'", nil
+}
+
+// ColumnsSql lists the columns of the current database as tablename and columnname.
+func (db *BaseDialect) ColumnsSql() (string, []interface{}) {
+	return "SELECT TABLE_NAME AS tablename, COLUMN_NAME AS columnname FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE()", nil
+}
+
+// IndexesSql lists the indexes of the current database as tablename and indexname.
+func (db *BaseDialect) IndexesSql() (string, []interface{}) {
+	return "SELECT DISTINCT TABLE_NAME AS tablename, INDEX_NAME AS indexname FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE()", nil
+}
+
+// TruncateTablesSql empties the tables and resets their auto increment counters.
+func (db *BaseDialect) TruncateTablesSql(tableNames []string) string {
+	if len(tableNames) == 0 {
+		return ""
+	}
+
+	statements := []string{"SET FOREIGN_KEY_CHECKS = 0"}
+	for _, name := range tableNames {
+		statements = append(statements, "TRUNCATE TABLE "+db.dialect.Quote(name))
+	}
+	statements = append(statements, "SET FOREIGN_KEY_CHECKS = 1")
+
+	return joinSql(statements...)
+}
+
+func (db *BaseDialect) CreateRoleSql(role *Role) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) CreateEventTriggerSql(trigger *EventTrigger) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) DropEventTriggerSql(name string) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) SetReplicaIdentitySql(tableName string, identity ReplicaIdentity) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) EnableRowLevelSecuritySql(tableName string, enable bool) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) ForceRowLevelSecuritySql(tableName string, force bool) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) LockTableSql(tableName string, mode LockMode) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) SetTableLoggedSql(tableName string, logged bool) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) ClusterTableSql(tableName string, indexName string) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) CreateCastSql(cast *Cast) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) CreateAggregateSql(aggregate *Aggregate) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) CreateTextSearchConfigSql(name string, copyFrom string, parser string) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) AlterTextSearchMappingSql(name string, tokenTypes []string, dictionaries []string, add bool) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) DropTextSearchConfigSql(name string) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) CreatePublicationSql(name string, tables []string) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) DropPublicationSql(name string) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) AddTableToPublicationSql(publication string, table string) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) DropTableFromPublicationSql(publication string, table string) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) CreateOperatorSql(operator *Operator) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) DropOperatorSql(name string, leftArg string, rightArg string) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) CreateOperatorClassSql(opClass *OperatorClass) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) DropOperatorClassSql(name string, method string) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) DropAggregateSql(name string, args []string) string {
+	return db.dialect.NoOpSql()
+}
+
+// AddEnumValueSql is a no-op, MySQL enums are column types.
+func (db *BaseDialect) AddEnumValueSql(typeName string, value string, ifNotExists bool) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) DropCastSql(source string, target string) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) AlterTableOwnerSql(tableName string, owner string) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) AlterSequenceOwnerSql(sequenceName string, owner string) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) AlterViewOwnerSql(viewName string, owner string) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) CreateStatisticsSql(name string, tableName string, columns []string, kinds []string) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) DropStatisticsSql(name string) string {
+	return db.dialect.NoOpSql()
+}
+
+func (db *BaseDialect) StatisticsCheckSql(name string) (string, []interface{}) {
 	return "", nil
 }
 
 func (db *BaseDialect) DropIndexSql(tableName string, index *Index) string {
 	quote := db.dialect.Quote
-	name := index.XName(tableName)
+	name := index.XNameWithLimit(tableName, db.dialect.MaxIdentifierLength())
 	return fmt.Sprintf("DROP INDEX %v ON %s", quote(name), quote(tableName))
 }
 
@@ -219,6 +720,11 @@ func (db *BaseDialect) UpdateTableSql(tableName string, columns []*Column) strin
 	return "-- NOT REQUIRED"
 }
 
+// AlterDatabaseCharsetSql changes the defaults new tables of the database get.
+func (db *BaseDialect) AlterDatabaseCharsetSql(dbName string, charset string, collation string) string {
+	return fmt.Sprintf("ALTER DATABASE %s CHARACTER SET %s COLLATE %s", db.dialect.Quote(dbName), charset, collation)
+}
+
 func (db *BaseDialect) ColString(col *Column) string {
 	sql := db.dialect.Quote(col.Name) + " "
 
@@ -239,10 +745,14 @@ func (db *BaseDialect) ColString(col *Column) string {
 		}
 	}
 
-	if col.Default != "" {
+	if col.Default != "" || col.IsDefaultNull {
 		sql += "DEFAULT " + db.dialect.Default(col) + " "
 	}
 
+	if col.Check != "" {
+		sql += "CHECK (" + col.Check + ") "
+	}
+
 	return sql
 }
 
@@ -263,10 +773,14 @@ func (db *BaseDialect) ColStringNoPk(col *Column) string {
 	// 	sql += "UNIQUE "
 	// }
 
-	if col.Default != "" {
+	if col.Default != "" || col.IsDefaultNull {
 		sql += "DEFAULT " + db.dialect.Default(col) + " "
 	}
 
+	if col.Check != "" {
+		sql += "CHECK (" + col.Check + ") "
+	}
+
 	return sql
 }
 
@@ -290,6 +804,51 @@ func (db *BaseDialect) CleanDB() error {
 	return nil
 }
 
+// NoOpSql returns a statement valid on every backend that the migrator never executes.
 func (db *BaseDialect) NoOpSql() string {
-	return "SELECT 0;"
+	return "SELECT 1"
+}
+
+// Version returns the server version like server_version_num, or 0 when unknown.
+func (db *BaseDialect) Version() (int, error) {
+	return db.dialect.VersionContext(context.Background())
+}
+
+// VersionContext is Version with a context for reading the version.
+func (db *BaseDialect) VersionContext(ctx context.Context) (int, error) {
+	return 0, nil
+}
+
+// MaxIdentifierLength is the longest table, column or index name MySQL keeps.
+func (db *BaseDialect) MaxIdentifierLength() int {
+	return 64
+}
+
+func joinSql(statements ...string) string {
+	parts := make([]string, 0, len(statements))
+	for _, stmt := range statements {
+		stmt = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(stmt), ";"))
+		if stmt != "" {
+			parts = append(parts, stmt)
+		}
+	}
+
+	return strings.Join(parts, ";\n")
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func rowPlaceholders(n int, rowCount int) string {
+	rows := make([]string, rowCount)
+	for i := range rows {
+		rows[i] = "(" + placeholders(n) + ")"
+	}
+
+	return strings.Join(rows, ", ")
+}
+
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
 }
@@ -0,0 +1,134 @@
+package migrator
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"xorm.io/xorm"
+)
+
+// DryRunResult describes what MigrateUp would do with a registered migration.
+type DryRunResult struct {
+	MigrationID string
+	WouldRun    bool
+	// Reason explains why the migration would be skipped.
+	Reason SkipReason
+	// SQL is the SQL of the migration, formatted for reading.
+	SQL string
+	// Destructive flags a migration that loses data, see DestructiveMigration.
+	Destructive bool
+}
+
+// DryRunWithConditions reports which pending migrations would run, without changing the database.
+func (mg *Migrator) DryRunWithConditions(ctx context.Context, d Dialect, engine *xorm.Engine) ([]DryRunResult, error) {
+	logMap, err := readMigrationLog(engine)
+	if err != nil {
+		return nil, err
+	}
+
+	sess := engine.NewSession().Context(ctx)
+	defer sess.Close()
+
+	results := make([]DryRunResult, 0, len(mg.migrations))
+	for _, m := range mg.migrations {
+		result := DryRunResult{
+			MigrationID: m.Id(),
+			SQL:         FormatSQL(loggableSql(m, d)),
+			Destructive: isDestructive(m),
+		}
+
+		if _, exists := logMap[m.Id()]; exists {
+			result.Reason = SkipAlreadyApplied
+			results = append(results, result)
+			continue
+		}
+
+		if mg.filter != nil && !mg.filter(m) {
+			result.Reason = SkipFiltered
+			results = append(results, result)
+			continue
+		}
+
+		fulfilled, err := evaluateCondition(m.GetCondition(), d, sess)
+		if err != nil {
+			return nil, err
+		}
+
+		if fulfilled {
+			result.WouldRun = true
+		} else {
+			result.Reason = SkipConditionFalse
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// PlannedMigration is the SQL of a registered migration, as PlanAll renders it.
+type PlannedMigration struct {
+	MigrationID string
+	// ConditionSQL and ConditionArgs check whether the migration runs.
+	ConditionSQL  string
+	ConditionArgs []interface{}
+	// SQL is the SQL of the migration, redacted for sensitive migrations.
+	SQL string
+	// Destructive flags a migration that loses data, see DestructiveMigration.
+	Destructive bool
+}
+
+// PlanAll renders the SQL of all registered migrations for the dialect.
+func (mg *Migrator) PlanAll(d Dialect) []PlannedMigration {
+	planned := make([]PlannedMigration, 0, len(mg.migrations))
+	for _, m := range mg.migrations {
+		plan := PlannedMigration{
+			MigrationID: m.Id(),
+			SQL:         loggableSql(m, d),
+			Destructive: isDestructive(m),
+		}
+
+		if condition := m.GetCondition(); condition != nil {
+			if _, ok := condition.(EvaluatingCondition); !ok {
+				plan.ConditionSQL, plan.ConditionArgs = condition.Sql(d)
+			}
+		}
+
+		planned = append(planned, plan)
+	}
+
+	return planned
+}
+
+// ExportToFile writes the SQL of all registered migrations to the file at path.
+func (mg *Migrator) ExportToFile(d Dialect, path string) error {
+	var out strings.Builder
+	for i, plan := range mg.PlanAll(d) {
+		if i > 0 {
+			out.WriteString("\n")
+		}
+
+		fmt.Fprintf(&out, "-- %s\n", plan.MigrationID)
+		if plan.ConditionSQL != "" {
+			fmt.Fprintf(&out, "-- condition: %s %v\n", plan.ConditionSQL, plan.ConditionArgs)
+		}
+
+		if plan.SQL == "" || plan.SQL == d.NoOpSql() {
+			continue
+		}
+
+		sql := FormatSQL(plan.SQL)
+		out.WriteString(sql)
+		if tokens := tokenizeSQL(sql); strings.HasPrefix(tokens[len(tokens)-1].text, "--") {
+			out.WriteString("\n")
+		}
+		if !strings.HasSuffix(sql, ";") {
+			out.WriteString(";")
+		}
+		out.WriteString("\n")
+	}
+
+	return os.WriteFile(path, []byte(out.String()), 0o644)
+}
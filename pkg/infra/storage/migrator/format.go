@@ -0,0 +1,186 @@
+package migrator
+
+import (
+	"strings"
+	"unicode"
+)
+
+var sqlKeywords = map[string]struct{}{
+	"add": {}, "all": {}, "alter": {}, "and": {}, "as": {}, "asc": {}, "begin": {}, "bigint": {},
+	"boolean": {}, "by": {}, "cascade": {}, "check": {}, "column": {}, "comment": {}, "constraint": {},
+	"create": {}, "default": {}, "delete": {}, "desc": {}, "distinct": {}, "drop": {}, "end": {},
+	"exists": {}, "foreign": {}, "from": {}, "group": {}, "having": {}, "if": {}, "in": {},
+	"including": {}, "index": {}, "inner": {}, "insert": {}, "int": {}, "integer": {}, "into": {},
+	"is": {}, "join": {}, "key": {}, "left": {}, "like": {}, "limit": {}, "not": {}, "null": {},
+	"offset": {}, "on": {}, "or": {}, "order": {}, "outer": {}, "owner": {}, "primary": {},
+	"references": {}, "rename": {}, "restrict": {}, "schema": {}, "select": {}, "sequence": {},
+	"set": {}, "smallint": {}, "table": {}, "text": {}, "then": {}, "timestamp": {}, "to": {},
+	"truncate": {}, "type": {}, "unique": {}, "update": {}, "using": {}, "values": {},
+	"varchar": {}, "view": {}, "where": {},
+}
+
+type sqlToken struct {
+	text        string
+	spaceBefore bool
+}
+
+// FormatSQL lays out generated SQL for people to read.
+func FormatSQL(sql string) string {
+	sql = strings.TrimSpace(sql)
+	if strings.Contains(sql, "$$") {
+		return sql
+	}
+
+	var out strings.Builder
+	depth := 0
+	createTable := false
+	tableBody := false
+	lineBreak := ""
+	var words []string
+	prev := ""
+
+	for i, tok := range tokenizeSQL(sql) {
+		if lineBreak != "" {
+			if !(prev == "," && strings.HasPrefix(tok.text, "--")) && !(tok.text == ")" && depth == 1 && tableBody) {
+				out.WriteString(lineBreak)
+			}
+			lineBreak = ""
+		}
+
+		switch tok.text {
+		case "(":
+			if depth == 0 && createTable && !tableBody {
+				out.WriteString(" (\n\t")
+				tableBody = true
+			} else {
+				if tok.spaceBefore && i > 0 {
+					out.WriteString(" ")
+				}
+				out.WriteString("(")
+			}
+			depth++
+		case ")":
+			depth--
+			if depth == 0 && tableBody {
+				out.WriteString("\n)")
+			} else {
+				out.WriteString(")")
+			}
+		case ",":
+			out.WriteString(",")
+			if depth == 1 && tableBody {
+				lineBreak = "\n\t"
+			}
+		case ";":
+			out.WriteString(";\n")
+			depth, createTable, tableBody, words = 0, false, false, nil
+		default:
+			s := out.String()
+			if (tok.spaceBefore || prev == ",") && len(s) > 0 &&
+				!strings.HasSuffix(s, "\n") && !strings.HasSuffix(s, "\t") && !strings.HasSuffix(s, "(") {
+				out.WriteString(" ")
+			}
+
+			text := tok.text
+			if _, ok := sqlKeywords[strings.ToLower(text)]; ok {
+				text = strings.ToUpper(text)
+			}
+			out.WriteString(text)
+
+			if strings.HasPrefix(text, "--") {
+				lineBreak = "\n"
+				if depth > 0 && tableBody {
+					lineBreak = "\n\t"
+				}
+				break
+			}
+			if strings.HasPrefix(text, "/*") {
+				break
+			}
+
+			if depth == 0 {
+				words = append(words, text)
+				if text == "TABLE" && words[0] == "CREATE" {
+					createTable = true
+				}
+			}
+		}
+		prev = tok.text
+	}
+
+	return strings.TrimSpace(out.String())
+}
+
+func tokenizeSQL(sql string) []sqlToken {
+	var tokens []sqlToken
+	runes := []rune(sql)
+	space := false
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		j := i + 1
+		switch {
+		case unicode.IsSpace(r):
+			space = true
+			i++
+			continue
+		case r == '(' || r == ')' || r == ',' || r == ';':
+		case startsWith(runes, i, "--"):
+			for j < len(runes) && runes[j] != '\n' {
+				j++
+			}
+		case startsWith(runes, i, "/*"):
+			j = i + 2
+			for j < len(runes) && !startsWith(runes, j, "*/") {
+				j++
+			}
+			j = min(j+2, len(runes))
+		case r == '\'' || r == '"':
+			j = scanQuoted(runes, i, false)
+		case (r == 'E' || r == 'e') && startsWith(runes, i+1, "'"):
+			j = scanQuoted(runes, i+1, true)
+		default:
+			for j < len(runes) && !unicode.IsSpace(runes[j]) && !strings.ContainsRune("(),;'\"", runes[j]) &&
+				!startsWith(runes, j, "--") && !startsWith(runes, j, "/*") {
+				j++
+			}
+		}
+		tokens = append(tokens, sqlToken{text: strings.TrimRightFunc(string(runes[i:j]), unicode.IsSpace), spaceBefore: space})
+		i = j
+		space = false
+	}
+
+	return tokens
+}
+
+func scanQuoted(runes []rune, start int, backslashEscapes bool) int {
+	quote := runes[start]
+	j := start + 1
+	for j < len(runes) {
+		if backslashEscapes && runes[j] == '\\' {
+			j += 2
+			continue
+		}
+		if runes[j] == quote {
+			if j+1 < len(runes) && runes[j+1] == quote {
+				j += 2
+				continue
+			}
+			return j + 1
+		}
+		j++
+	}
+
+	return len(runes)
+}
+
+func startsWith(runes []rune, i int, prefix string) bool {
+	for _, r := range prefix {
+		if i >= len(runes) || runes[i] != r {
+			return false
+		}
+		i++
+	}
+
+	return true
+}
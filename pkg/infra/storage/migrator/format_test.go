@@ -0,0 +1,82 @@
+package migrator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatSQL(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewPostgresDialect(nil)
+	table := Table{
+		Name: "user",
+		Columns: []*Column{
+			{Name: "id", Type: DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "login", Type: DB_NVarchar, Length: 255},
+		},
+	}
+
+	assert.Equal("CREATE TABLE IF NOT EXISTS \"user\" (\n\t\"id\" BIGSERIAL PRIMARY KEY NOT NULL,\n\t\"login\" VARCHAR(255) NOT NULL\n)",
+		FormatSQL(NewAddTableMigration(table).SQL(d)))
+
+	assert.Equal(`SELECT "id", 'it''s from here' FROM "user" WHERE "login" IN ('a', 'b');`+"\n"+`DROP TABLE "user"`,
+		FormatSQL(`select   "id",'it''s from here' from "user" where "login" in ('a', 'b'); drop table "user"`))
+
+	assert.Equal(`ALTER TABLE "order" ALTER "amount" TYPE INTEGER USING "amount"::integer`,
+		FormatSQL(`alter table "order" alter "amount" type integer using "amount"::integer`))
+}
+
+func TestFormatSQLComments(t *testing.T) {
+	assert := assert.New(t)
+
+	// a line comment keeps ending its line, its words are left alone
+	assert.Equal("-- don't touch the index\nDROP INDEX \"IDX_user_login\"",
+		FormatSQL("-- don't touch the index\ndrop index \"IDX_user_login\""))
+	assert.Equal("DELETE FROM \"session\" -- from the last run\nWHERE \"expired\"",
+		FormatSQL("delete from \"session\" -- from the last run\nwhere \"expired\""))
+	assert.Equal("-- tags\nCREATE TABLE \"tag\" (\n\t\"id\" BIGINT, -- the key\n\t\"name\" TEXT -- not unique\n)",
+		FormatSQL("-- tags\ncreate table \"tag\" (\"id\" bigint, -- the key\n\"name\" text -- not unique\n)"))
+	assert.Equal("SELECT /* it's a hint, select from */ 1", FormatSQL("select /* it's a hint, select from */ 1"))
+}
+
+func TestFormatSQLEscapeStrings(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(`UPDATE "user" SET "bio" = E'it\'s a (test), from here' WHERE "id" = 1`,
+		FormatSQL(`update "user" set "bio" = E'it\'s a (test), from here' where "id" = 1`))
+	assert.Equal(`SELECT e'a\\', 'b'`, FormatSQL(`select e'a\\','b'`))
+	// without the prefix a backslash is an ordinary character
+	assert.Equal(`SELECT 'a\', 'b'`, FormatSQL(`select 'a\','b'`))
+}
+
+func TestExportToFile(t *testing.T) {
+	mg, _ := newTestMigrator(t)
+	mg.AddMigration("create tag table", NewAddTableMigration(Table{
+		Name:    "tag",
+		Columns: []*Column{{Name: "id", Type: DB_BigInt, IsPrimaryKey: true}},
+	}))
+	index := NewRawSqlMigration(`create index "IDX_tag_id" on "tag" ("id") -- for lookups`)
+	index.Condition = &IfIndexNotExistsCondition{TableName: "tag", IndexName: "IDX_tag_id"}
+	mg.AddMigration("index tag", index)
+	mg.AddMigration("nothing", NewRawSqlMigration(mg.Dialect.NoOpSql()))
+
+	path := filepath.Join(t.TempDir(), "migrations.sql")
+	require.NoError(t, mg.ExportToFile(mg.Dialect, path))
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "-- create tag table\n"+
+		"-- condition: SELECT 1 FROM pg_tables WHERE schemaname = current_schema() AND tablename = ? [tag]\n"+
+		"CREATE TABLE IF NOT EXISTS \"tag\" (\n\t\"id\" BIGINT PRIMARY KEY NOT NULL\n);\n"+
+		"\n"+
+		"-- index tag\n"+
+		"-- condition: SELECT 1 FROM pg_indexes WHERE tablename = ? AND indexname = ? [tag IDX_tag_id]\n"+
+		"CREATE INDEX \"IDX_tag_id\" ON \"tag\" (\"id\") -- for lookups\n;\n"+
+		"\n"+
+		"-- nothing\n", string(content))
+}
@@ -1,12 +1,36 @@
 package migrator
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"xorm.io/xorm"
 )
 
+var ErrIrreversibleMigration = errors.New("migration cannot be reversed")
+
 type MigrationBase struct {
 	id        string
 	Condition MigrationCondition
+
+	before                 []string
+	after                  []string
+	locks                  []tableLock
+	disableTriggers        bool
+	disableForeignKeyCheck bool
+}
+
+type tableLock struct {
+	tableName string
+	mode      LockMode
 }
 
 func (m *MigrationBase) Id() string {
@@ -21,10 +45,76 @@ func (m *MigrationBase) GetCondition() MigrationCondition {
 	return m.Condition
 }
 
+// Before adds a statement to run ahead of the migration, in the same transaction.
+func (m *MigrationBase) Before(sql string) {
+	m.before = append(m.before, sql)
+}
+
+// After adds a statement to run once the migration succeeded, in the same transaction.
+func (m *MigrationBase) After(sql string) {
+	m.after = append(m.after, sql)
+}
+
+// LockTable locks the table in the transaction of the migration.
+func (m *MigrationBase) LockTable(table Table, mode LockMode) {
+	if !slices.Contains(lockModes, mode) {
+		panic(fmt.Sprintf("unknown lock mode %q", mode))
+	}
+
+	m.locks = append(m.locks, tableLock{tableName: table.Name, mode: mode})
+}
+
+func (m *MigrationBase) locksTables() bool {
+	return len(m.locks) > 0
+}
+
+// WithoutForeignKeyChecks suspends foreign key checks while the migration runs.
+func (m *MigrationBase) WithoutForeignKeyChecks() {
+	m.disableForeignKeyCheck = true
+}
+
+func (m *MigrationBase) beforeSql(d Dialect) []string {
+	var statements []string
+	for _, lock := range m.locks {
+		if sql := d.LockTableSql(lock.tableName, lock.mode); sql != d.NoOpSql() {
+			statements = append(statements, sql)
+		}
+	}
+
+	if m.disableTriggers {
+		statements = append(statements, d.DisableTriggersSql())
+	}
+
+	if m.disableForeignKeyCheck {
+		statements = append(statements, d.DisableForeignKeyChecksSql())
+	}
+
+	return append(statements, m.before...)
+}
+
+func (m *MigrationBase) afterSql(d Dialect) []string {
+	return m.after
+}
+
+func (m *MigrationBase) restoreSql(d Dialect) []string {
+	var statements []string
+	if m.disableForeignKeyCheck {
+		statements = append(statements, d.EnableForeignKeyChecksSql())
+	}
+
+	if m.disableTriggers {
+		statements = append(statements, d.EnableTriggersSql())
+	}
+
+	return statements
+}
+
 type RawSqlMigration struct {
 	MigrationBase
 
-	sql map[string]string
+	sql              map[string]string
+	nonTransactional bool
+	allowRepeat      bool
 }
 
 func NewRawSqlMigration(sql string) *RawSqlMigration {
@@ -37,6 +127,10 @@ func NewRawSqlMigration(sql string) *RawSqlMigration {
 
 func (m *RawSqlMigration) SQL(dialect Dialect) string {
 	if m.sql != nil {
+		if val := m.versionedSql(dialect); val != "" {
+			return val
+		}
+
 		if val := m.sql[dialect.DriverName()]; val != "" {
 			return val
 		}
@@ -49,7 +143,114 @@ func (m *RawSqlMigration) SQL(dialect Dialect) string {
 	return dialect.NoOpSql()
 }
 
+// AllowRepeat declares the SQL safe to run more than once.
+func (m *RawSqlMigration) AllowRepeat() *RawSqlMigration {
+	m.allowRepeat = true
+	return m
+}
+
+// OutsideTransaction runs the SQL outside of a transaction.
+func (m *RawSqlMigration) OutsideTransaction() *RawSqlMigration {
+	m.nonTransactional = true
+	return m
+}
+
+func (m *RawSqlMigration) NonTransactional() bool {
+	return m.nonTransactional
+}
+
+func (m *RawSqlMigration) volatileSql() bool {
+	for key := range m.sql {
+		if strings.Contains(key, ">=") {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateDialect refuses SQL set per server version when the version is unknown.
+func (m *RawSqlMigration) ValidateDialect(d Dialect) error {
+	if !m.hasVersionedSql(d) {
+		return nil
+	}
+
+	if _, err := d.Version(); err != nil {
+		return fmt.Errorf("cannot choose the SQL for the server version: %w", err)
+	}
+
+	return nil
+}
+
+func (m *RawSqlMigration) hasVersionedSql(dialect Dialect) bool {
+	prefix := dialect.DriverName() + ">="
+	for key := range m.sql {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *RawSqlMigration) versionedSql(dialect Dialect) string {
+	if !m.hasVersionedSql(dialect) {
+		return ""
+	}
+
+	version, err := dialect.Version()
+	if err != nil {
+		return ""
+	}
+
+	prefix := dialect.DriverName() + ">="
+
+	var sql string
+	best := -1
+	for key, val := range m.sql {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		required, _ := parseVersion(strings.TrimPrefix(key, prefix))
+		if required <= best || version < required {
+			continue
+		}
+
+		sql, best = val, required
+	}
+
+	return sql
+}
+
+func parseVersion(s string) (int, error) {
+	major, minor, _ := strings.Cut(s, ".")
+
+	version, err := strconv.Atoi(major)
+	if err != nil {
+		return 0, fmt.Errorf("invalid version %q: %w", s, err)
+	}
+
+	n := 0
+	if minor != "" {
+		if n, err = strconv.Atoi(minor); err != nil {
+			return 0, fmt.Errorf("invalid version %q: %w", s, err)
+		}
+	}
+
+	if version < 10 {
+		return version*10000 + n*100, nil
+	}
+
+	return version*10000 + n, nil
+}
+
+// Set registers the SQL for a dialect, optionally with a minimum version like "postgres>=14".
 func (m *RawSqlMigration) Set(dialect string, sql string) *RawSqlMigration {
+	if _, version, ok := strings.Cut(dialect, ">="); ok {
+		if _, err := parseVersion(version); err != nil {
+			panic(fmt.Sprintf("invalid dialect %q: %v", dialect, err))
+		}
+	}
+
 	if m.sql == nil {
 		m.sql = make(map[string]string)
 	}
@@ -62,14 +263,47 @@ func (m *RawSqlMigration) Default(sql string) *RawSqlMigration {
 	return m.Set("default", sql)
 }
 
+// DisableTriggers keeps triggers from firing while the migration runs.
+func (m *RawSqlMigration) DisableTriggers() *RawSqlMigration {
+	m.disableTriggers = true
+	return m
+}
+
 func (m *RawSqlMigration) Postgres(sql string) *RawSqlMigration {
 	return m.Set(POSTGRES, sql)
 }
 
+func (m *RawSqlMigration) MySQL(sql string) *RawSqlMigration {
+	return m.Set(MYSQL, sql)
+}
+
+func (m *RawSqlMigration) SQLite(sql string) *RawSqlMigration {
+	return m.Set(SQLITE, sql)
+}
+
+func (m *RawSqlMigration) MSSQL(sql string) *RawSqlMigration {
+	return m.Set(MSSQL, sql)
+}
+
+// FuncMigration runs Go code within the transaction of the migration.
+type FuncMigration struct {
+	MigrationBase
+	Run func(ctx context.Context, sess *xorm.Session) error
+}
+
+func NewFuncMigration(run func(ctx context.Context, sess *xorm.Session) error) *FuncMigration {
+	return &FuncMigration{Run: run}
+}
+
+func (m *FuncMigration) SQL(d Dialect) string {
+	return d.NoOpSql()
+}
+
 type AddColumnMigration struct {
 	MigrationBase
 	tableName string
 	column    *Column
+	fill      string
 }
 
 func NewAddColumnMigration(table Table, col *Column) *AddColumnMigration {
@@ -88,14 +322,122 @@ func (m *AddColumnMigration) Column(col *Column) *AddColumnMigration {
 	return m
 }
 
+// Fill sets the value existing rows get for a NOT NULL column without a default.
+func (m *AddColumnMigration) Fill(value string) *AddColumnMigration {
+	m.fill = value
+	return m
+}
+
+func (m *AddColumnMigration) check(sess *xorm.Session, d Dialect) error {
+	if m.column.Nullable || m.column.Default != "" || m.fill != "" {
+		return nil
+	}
+
+	hasRows, err := tableHasRows(sess, d, m.tableName)
+	if err != nil {
+		return err
+	}
+
+	if hasRows {
+		return fmt.Errorf("column %s.%s is NOT NULL without a default, existing rows need a value from Fill",
+			m.tableName, m.column.Name)
+	}
+
+	return nil
+}
+
 func (m *AddColumnMigration) SQL(dialect Dialect) string {
-	return dialect.AddColumnSql(m.tableName, m.column)
+	if m.fill == "" {
+		return dialect.AddColumnSql(m.tableName, m.column)
+	}
+
+	nullable := *m.column
+	nullable.Nullable = true
+	return joinSql(
+		dialect.AddColumnSql(m.tableName, &nullable),
+		fmt.Sprintf("UPDATE %s SET %s = %s", dialect.Quote(m.tableName), dialect.Quote(m.column.Name), m.fill),
+		dialect.SetNotNullSql(m.tableName, m.column),
+	)
 }
 
-type AddIndexMigration struct {
+func (m *AddColumnMigration) DownSQL(dialect Dialect) (string, error) {
+	return dialect.DropColumnSql(m.tableName, m.column), nil
+}
+
+// AddColumnsMigration adds several columns to a table at once.
+type AddColumnsMigration struct {
 	MigrationBase
 	tableName string
-	index     *Index
+	columns   []*Column
+}
+
+func NewAddColumnsMigration(table Table, cols []*Column) *AddColumnsMigration {
+	m := &AddColumnsMigration{tableName: table.Name, columns: cols}
+	names := make([]string, 0, len(cols))
+	for _, col := range cols {
+		names = append(names, col.Name)
+	}
+	m.Condition = &IfColumnsNotExistCondition{TableName: table.Name, ColumnNames: names}
+	return m
+}
+
+// Validate refuses an empty column list.
+func (m *AddColumnsMigration) Validate() error {
+	if len(m.columns) == 0 {
+		return fmt.Errorf("no columns to add to %s", m.tableName)
+	}
+
+	return nil
+}
+
+func (m *AddColumnsMigration) check(sess *xorm.Session, d Dialect) error {
+	for _, col := range m.columns {
+		if col.Nullable || col.Default != "" {
+			continue
+		}
+
+		hasRows, err := tableHasRows(sess, d, m.tableName)
+		if err != nil {
+			return err
+		}
+
+		if hasRows {
+			return fmt.Errorf("column %s.%s is NOT NULL without a default, existing rows need a value",
+				m.tableName, col.Name)
+		}
+
+		return nil
+	}
+
+	return nil
+}
+
+func tableHasRows(sess *xorm.Session, d Dialect, tableName string) (bool, error) {
+	results, err := sess.SQL("SELECT 1 FROM " + d.Quote(tableName) + d.Limit(1)).Query()
+	if err != nil {
+		return false, err
+	}
+
+	return len(results) > 0, nil
+}
+
+func (m *AddColumnsMigration) SQL(dialect Dialect) string {
+	return dialect.AddColumnsSql(m.tableName, m.columns)
+}
+
+func (m *AddColumnsMigration) DownSQL(dialect Dialect) (string, error) {
+	statements := make([]string, 0, len(m.columns))
+	for _, col := range m.columns {
+		statements = append(statements, dialect.DropColumnSql(m.tableName, col))
+	}
+	return joinSql(statements...), nil
+}
+
+type AddIndexMigration struct {
+	MigrationBase
+	tableName    string
+	index        *Index
+	concurrently bool
 }
 
 func NewAddIndexMigration(table Table, index *Index) *AddIndexMigration {
@@ -109,10 +451,41 @@ func (m *AddIndexMigration) Table(tableName string) *AddIndexMigration {
 	return m
 }
 
+// Concurrently builds the index without locking out writes.
+func (m *AddIndexMigration) Concurrently() *AddIndexMigration {
+	m.concurrently = true
+	return m
+}
+
+func (m *AddIndexMigration) NonTransactional() bool {
+	return m.concurrently
+}
+
 func (m *AddIndexMigration) SQL(dialect Dialect) string {
+	if m.concurrently {
+		return dialect.CreateIndexConcurrentlySql(m.tableName, m.index)
+	}
+
 	return dialect.CreateIndexSql(m.tableName, m.index)
 }
 
+func (m *AddIndexMigration) DownSQL(dialect Dialect) (string, error) {
+	return dialect.DropIndexSql(m.tableName, m.index), nil
+}
+
+func (m *AddIndexMigration) legacySql(dialect Dialect) string {
+	return unshortenedIndexSql(m.SQL(dialect), dialect, m.tableName, m.index)
+}
+
+func unshortenedIndexSql(sql string, d Dialect, tableName string, index *Index) string {
+	full := index.XName(tableName)
+	short := index.XNameWithLimit(tableName, d.MaxIdentifierLength())
+	if full == short {
+		return sql
+	}
+	return strings.ReplaceAll(sql, d.Quote(short), d.Quote(full))
+}
+
 type DropIndexMigration struct {
 	MigrationBase
 	tableName string
@@ -132,6 +505,10 @@ func (m *DropIndexMigration) SQL(dialect Dialect) string {
 	return dialect.DropIndexSql(m.tableName, m.index)
 }
 
+func (m *DropIndexMigration) legacySql(dialect Dialect) string {
+	return unshortenedIndexSql(m.SQL(dialect), dialect, m.tableName, m.index)
+}
+
 type AddTableMigration struct {
 	MigrationBase
 	table Table
@@ -147,30 +524,192 @@ func NewAddTableMigration(table Table) *AddTableMigration {
 			table.Uniques = append(table.Uniques, col.Name)
 		}
 	}
-	return &AddTableMigration{table: table}
+	m := &AddTableMigration{table: table}
+	m.Condition = &IfTableNotExistsCondition{TableName: table.Name}
+	return m
+}
+
+// WithLike creates the table with the structure of tableName.
+func (m *AddTableMigration) WithLike(tableName string) *AddTableMigration {
+	m.table.LikeTable = tableName
+	return m
 }
 
 func (m *AddTableMigration) SQL(d Dialect) string {
-	return d.CreateTableSql(&m.table)
+	statements := []string{d.CreateTableSql(&m.table)}
+	if m.table.Comment != "" {
+		statements = append(statements, d.CommentOnTableSql(m.table.Name, m.table.Comment))
+	}
+	if m.table.RowSecurityEnabled {
+		statements = append(statements, d.EnableRowLevelSecuritySql(m.table.Name, true))
+	}
+
+	statements = slices.DeleteFunc(statements, func(stmt string) bool { return stmt == d.NoOpSql() })
+	if len(statements) == 1 {
+		return statements[0]
+	}
+	return joinSql(statements...)
+}
+
+func (m *AddTableMigration) DownSQL(d Dialect) (string, error) {
+	return d.DropTable(m.table.Name), nil
 }
 
+const droppedTableTimeFormat = "20060102150405"
+
 type DropTableMigration struct {
 	MigrationBase
 	tableName string
+	// RenameBeforeDrop renames the table to _drop_<name>_<timestamp> instead of dropping it.
+	RenameBeforeDrop bool
+	renamedAt        time.Time
 }
 
 func NewDropTableMigration(tableName string) *DropTableMigration {
-	return &DropTableMigration{tableName: tableName}
+	m := &DropTableMigration{tableName: tableName}
+	m.Condition = &IfTableExistsCondition{TableName: tableName}
+	return m
 }
 
+// SQL renames the table with the time of the run when RenameBeforeDrop is set.
 func (m *DropTableMigration) SQL(d Dialect) string {
-	return d.DropTable(m.tableName)
+	if !m.RenameBeforeDrop {
+		return d.DropTable(m.tableName)
+	}
+
+	renamedAt := m.renamedAt
+	if renamedAt.IsZero() {
+		renamedAt = time.Now()
+	}
+
+	newName := droppedTablePrefix(m.tableName, d) + renamedAt.UTC().Format(droppedTableTimeFormat)
+	return NewRenameTableMigration(m.tableName, newName).SQL(d)
+}
+
+func (m *DropTableMigration) setRunTime(t time.Time) {
+	m.renamedAt = t
+}
+
+// Destructive is false when the table is only renamed.
+func (m *DropTableMigration) Destructive() bool {
+	return !m.RenameBeforeDrop
+}
+
+func (m *DropTableMigration) volatileSql() bool {
+	return m.RenameBeforeDrop
+}
+
+func droppedTablePrefix(tableName string, d Dialect) string {
+	const prefix = "_drop_"
+
+	maxLen := d.MaxIdentifierLength() - len(prefix) - len("_") - len(droppedTableTimeFormat)
+	if len(tableName) > maxLen {
+		tableName = tableName[:maxLen]
+	}
+
+	return prefix + tableName + "_"
+}
+
+// DropRenamedTableMigration drops the tables renamed by RenameBeforeDrop after delay.
+type DropRenamedTableMigration struct {
+	MigrationBase
+	tableName string
+	delay     time.Duration
+}
+
+func NewDropRenamedTableMigration(tableName string, delay time.Duration) *DropRenamedTableMigration {
+	return &DropRenamedTableMigration{tableName: tableName, delay: delay}
+}
+
+func (m *DropRenamedTableMigration) SQL(d Dialect) string {
+	return d.NoOpSql()
+}
+
+func (m *DropRenamedTableMigration) Destructive() bool {
+	return true
+}
+
+func (m *DropRenamedTableMigration) due(sess *xorm.Session, mg *Migrator) (bool, error) {
+	renamed, err := m.renamedTables(sess, mg.Dialect)
+	if err != nil {
+		return false, err
+	}
+
+	for _, renamedAt := range renamed {
+		if mg.now().Sub(renamedAt) < m.delay {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func (m *DropRenamedTableMigration) Exec(sess *xorm.Session, mg *Migrator) error {
+	renamed, err := m.renamedTables(sess, mg.Dialect)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(renamed)) {
+		if _, err := sess.Exec(mg.Dialect.DropTable(name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *DropRenamedTableMigration) renamedTables(sess *xorm.Session, d Dialect) (map[string]time.Time, error) {
+	prefix := droppedTablePrefix(m.tableName, d)
+
+	sql, args := d.TablesSql()
+	results, err := sess.SQL(sql, args...).Query()
+	if err != nil {
+		return nil, err
+	}
+
+	renamed := make(map[string]time.Time)
+	for _, row := range results {
+		name := string(row["tablename"])
+		suffix, ok := strings.CutPrefix(name, prefix)
+		if !ok {
+			continue
+		}
+
+		renamedAt, err := time.Parse(droppedTableTimeFormat, suffix)
+		if err != nil {
+			continue
+		}
+		renamed[name] = renamedAt
+	}
+
+	return renamed, nil
+}
+
+type CreateViewMigration struct {
+	MigrationBase
+	viewName   string
+	definition string
+}
+
+func NewCreateViewMigration(viewName string, definition string) *CreateViewMigration {
+	return &CreateViewMigration{viewName: viewName, definition: definition}
+}
+
+func (m *CreateViewMigration) SQL(d Dialect) string {
+	return d.CreateViewSql(m.viewName, m.definition)
+}
+
+func (m *CreateViewMigration) DownSQL(d Dialect) (string, error) {
+	return d.DropViewSql(m.viewName), nil
 }
 
 type RenameTableMigration struct {
 	MigrationBase
-	oldName string
-	newName string
+	oldName      string
+	newName      string
+	indices      []*Index
+	sequenceCols []string
 }
 
 func NewRenameTableMigration(oldName string, newName string) *RenameTableMigration {
@@ -183,8 +722,27 @@ func (m *RenameTableMigration) Rename(oldName string, newName string) *RenameTab
 	return m
 }
 
+// WithDependents also renames the indices and serial sequences of the table.
+func (m *RenameTableMigration) WithDependents(table Table) *RenameTableMigration {
+	m.indices = table.Indices
+	m.sequenceCols = nil
+	for _, col := range table.Columns {
+		if col.IsAutoIncrement {
+			m.sequenceCols = append(m.sequenceCols, col.Name)
+		}
+	}
+	return m
+}
+
 func (m *RenameTableMigration) SQL(d Dialect) string {
-	return d.RenameTable(m.oldName, m.newName)
+	statements := []string{d.RenameTable(m.oldName, m.newName)}
+	for _, index := range m.indices {
+		statements = append(statements, d.RenameIndexSql(m.oldName, m.newName, index))
+	}
+	for _, col := range m.sequenceCols {
+		statements = append(statements, d.RenameSequenceSql(m.oldName, m.newName, col))
+	}
+	return joinSql(statements...)
 }
 
 type CopyTableDataMigration struct {
@@ -194,6 +752,10 @@ type CopyTableDataMigration struct {
 	sourceCols  []string
 	targetCols  []string
 	//colMap      map[string]string
+	chunkKey  string
+	chunkSize int64
+	// WithVerify fails the migration when the copied rows differ from the source.
+	WithVerify bool
 }
 
 func NewCopyTableDataMigration(targetTable string, sourceTable string, colMap map[string]string) *CopyTableDataMigration {
@@ -205,24 +767,464 @@ func NewCopyTableDataMigration(targetTable string, sourceTable string, colMap ma
 	return m
 }
 
-func (m *CopyTableDataMigration) SQL(d Dialect) string {
-	return d.CopyTableData(m.sourceTable, m.targetTable, m.sourceCols, m.targetCols)
+// InChunks copies the data in ranges of chunkSize keys, committing each range.
+func (m *CopyTableDataMigration) InChunks(keyCol string, chunkSize int64) *CopyTableDataMigration {
+	m.chunkKey = keyCol
+	m.chunkSize = chunkSize
+	return m
 }
 
-type TableCharsetMigration struct {
-	MigrationBase
-	tableName string
-	columns   []*Column
+// DisableTriggers keeps triggers from firing on the copied rows.
+func (m *CopyTableDataMigration) DisableTriggers() *CopyTableDataMigration {
+	m.disableTriggers = true
+	return m
 }
 
-func NewTableCharsetMigration(tableName string, columns []*Column) *TableCharsetMigration {
-	return &TableCharsetMigration{tableName: tableName, columns: columns}
+// DownSQL refuses to reverse the copy.
+func (m *CopyTableDataMigration) DownSQL(d Dialect) (string, error) {
+	return "", ErrIrreversibleMigration
 }
 
-func (m *TableCharsetMigration) SQL(d Dialect) string {
+func (m *CopyTableDataMigration) SQL(d Dialect) string {
+	return d.CopyTableData(m.sourceTable, m.targetTable, m.sourceCols, m.targetCols)
+}
+
+func (m *CopyTableDataMigration) Resumable() bool {
+	return m.chunkKey != "" && m.chunkSize > 0
+}
+
+func (m *CopyTableDataMigration) ExecStep(sess *xorm.Session, mg *Migrator, checkpoint string) (string, int64, error) {
+	results, err := sess.SQL(mg.Dialect.KeyRangeSql(m.sourceTable, m.chunkKey)).Query()
+	if err != nil {
+		return "", 0, err
+	}
+
+	if len(results) == 0 || len(results[0]["max_key"]) == 0 {
+		return "", 0, nil
+	}
+
+	maxKey, err := strconv.ParseInt(string(results[0]["max_key"]), 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid chunk key %q: %w", results[0]["max_key"], err)
+	}
+
+	var lower int64
+	if checkpoint == "" {
+		minKey, err := strconv.ParseInt(string(results[0]["min_key"]), 10, 64)
+		if err != nil {
+			return "", 0, fmt.Errorf("invalid chunk key %q: %w", results[0]["min_key"], err)
+		}
+		lower = minKey - 1
+	} else {
+		lower, err = strconv.ParseInt(checkpoint, 10, 64)
+		if err != nil {
+			return "", 0, fmt.Errorf("invalid checkpoint %q: %w", checkpoint, err)
+		}
+	}
+
+	if lower >= maxKey {
+		return "", 0, nil
+	}
+
+	upper := lower + m.chunkSize
+	sql := mg.Dialect.CopyTableDataRangeSql(m.sourceTable, m.targetTable, m.sourceCols, m.targetCols, m.chunkKey)
+	res, err := sess.Exec(sql, lower, upper)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if m.WithVerify {
+		where := fmt.Sprintf(" WHERE %s > ? AND %s <= ?", mg.Dialect.Quote(m.chunkKey), mg.Dialect.Quote(m.chunkKey))
+		if err := m.verifyCount(sess, mg.Dialect, res, where, lower, upper); err != nil {
+			return "", 0, err
+		}
+	}
+
+	rowsAffected, _ := res.RowsAffected()
+	return strconv.FormatInt(upper, 10), rowsAffected, nil
+}
+
+func (m *CopyTableDataMigration) verify(sess *xorm.Session, d Dialect, res sql.Result) error {
+	if !m.WithVerify {
+		return nil
+	}
+
+	return m.verifyCount(sess, d, res, "")
+}
+
+func (m *CopyTableDataMigration) verifyCount(sess *xorm.Session, d Dialect, res sql.Result, where string, args ...interface{}) error {
+	copied, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("counting rows copied to %s: %w", m.targetTable, err)
+	}
+
+	results, err := sess.SQL("SELECT COUNT(*) AS count FROM "+d.Quote(m.sourceTable)+where, args...).Query()
+	if err != nil {
+		return fmt.Errorf("counting rows of %s: %w", m.sourceTable, err)
+	}
+
+	sourceCount, err := strconv.ParseInt(string(results[0]["count"]), 10, 64)
+	if err != nil {
+		return fmt.Errorf("counting rows of %s: %w", m.sourceTable, err)
+	}
+
+	if sourceCount != copied {
+		return fmt.Errorf("copy from %s to %s is incomplete: %d source rows, %d copied rows",
+			m.sourceTable, m.targetTable, sourceCount, copied)
+	}
+
+	return nil
+}
+
+// ReorderColumnsMigration rebuilds a table with its columns in the given order.
+type ReorderColumnsMigration struct {
+	MigrationBase
+	table        Table
+	desiredOrder []string
+}
+
+func NewReorderColumnsMigration(table Table, desiredOrder []string) *ReorderColumnsMigration {
+	return &ReorderColumnsMigration{table: table, desiredOrder: desiredOrder}
+}
+
+// Validate refuses an order that is not a permutation of the table's columns.
+func (m *ReorderColumnsMigration) Validate() error {
+	if len(m.desiredOrder) != len(m.table.Columns) {
+		return fmt.Errorf("column order of %s has %d columns, the table has %d",
+			m.table.Name, len(m.desiredOrder), len(m.table.Columns))
+	}
+
+	seen := make(map[string]bool, len(m.desiredOrder))
+	for _, name := range m.desiredOrder {
+		if m.column(name) == nil {
+			return fmt.Errorf("column order of %s has unknown column %s", m.table.Name, name)
+		}
+		if seen[name] {
+			return fmt.Errorf("column order of %s has column %s twice", m.table.Name, name)
+		}
+		seen[name] = true
+	}
+
+	return nil
+}
+
+func (m *ReorderColumnsMigration) column(name string) *Column {
+	for _, col := range m.table.Columns {
+		if col.Name == name {
+			return col
+		}
+	}
+	return nil
+}
+
+func (m *ReorderColumnsMigration) SQL(d Dialect) string {
+	rebuilt := m.table
+	rebuilt.Name = m.table.Name + "_reorder"
+	rebuilt.Indices = nil
+	rebuilt.Columns = make([]*Column, 0, len(m.desiredOrder))
+	for _, name := range m.desiredOrder {
+		rebuilt.Columns = append(rebuilt.Columns, m.column(name))
+	}
+
+	rebuilt.ForeignKeys = make([]ForeignKey, 0, len(m.table.ForeignKeys))
+	for _, fk := range m.table.ForeignKeys {
+		fk.Name = fk.XName(m.table.Name)
+		rebuilt.ForeignKeys = append(rebuilt.ForeignKeys, fk)
+	}
+
+	statements := []string{
+		NewAddTableMigration(rebuilt).SQL(d),
+		d.CopyTableData(m.table.Name, rebuilt.Name, m.desiredOrder, m.desiredOrder),
+		d.DropTable(m.table.Name),
+		NewRenameTableMigration(rebuilt.Name, m.table.Name).WithDependents(rebuilt).SQL(d),
+	}
+	for _, suffix := range m.generatedConstraintSuffixes() {
+		statements = append(statements, d.RenameConstraintSql(m.table.Name, rebuilt.Name+suffix, m.table.Name+suffix))
+	}
+	for _, col := range rebuilt.Columns {
+		if col.IsAutoIncrement {
+			statements = append(statements, d.ResetSequenceSql(m.table.Name, col.Name))
+		}
+	}
+	for _, index := range m.table.Indices {
+		statements = append(statements, d.CreateIndexSql(m.table.Name, index))
+	}
+
+	statements = slices.DeleteFunc(statements, func(stmt string) bool { return stmt == d.NoOpSql() })
+	return joinSql(statements...)
+}
+
+func (m *ReorderColumnsMigration) generatedConstraintSuffixes() []string {
+	var suffixes []string
+	if len(m.table.PrimaryKeys) > 0 || slices.ContainsFunc(m.table.Columns, func(col *Column) bool { return col.IsPrimaryKey }) {
+		suffixes = append(suffixes, "_pkey")
+	}
+	if len(m.table.Uniques) > 0 {
+		suffixes = append(suffixes, "_"+strings.Join(m.table.Uniques, "_")+"_key")
+	}
+
+	return suffixes
+}
+
+func (m *ReorderColumnsMigration) Exec(sess *xorm.Session, mg *Migrator) error {
+	views, err := queryDependentViews(sess, mg.Dialect, m.table.Name)
+	if err != nil {
+		return err
+	}
+
+	foreignKeys, err := queryInboundForeignKeys(sess, mg.Dialect, m.table.Name)
+	if err != nil {
+		return err
+	}
+
+	if err := views.drop(sess, mg.Dialect); err != nil {
+		return err
+	}
+
+	for _, fk := range foreignKeys {
+		if _, err := sess.Exec(fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", fk.qualifiedTableName(mg.Dialect), mg.Dialect.Quote(fk.name))); err != nil {
+			return err
+		}
+	}
+
+	if _, err := sess.Exec(m.SQL(mg.Dialect)); err != nil {
+		return err
+	}
+
+	for _, fk := range foreignKeys {
+		if _, err := sess.Exec(fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s", fk.qualifiedTableName(mg.Dialect), mg.Dialect.Quote(fk.name), fk.definition)); err != nil {
+			return fmt.Errorf("failed to recreate foreign key %s: %w", fk.name, err)
+		}
+	}
+
+	return views.create(sess, mg.Dialect)
+}
+
+type inboundForeignKey struct {
+	schema     string
+	tableName  string
+	name       string
+	definition string
+}
+
+func (fk inboundForeignKey) qualifiedTableName(d Dialect) string {
+	return d.Quote(fk.schema) + "." + d.Quote(fk.tableName)
+}
+
+func queryInboundForeignKeys(sess *xorm.Session, d Dialect, tableName string) ([]inboundForeignKey, error) {
+	sql, args := d.InboundForeignKeysSql(tableName)
+	if sql == "" {
+		return nil, nil
+	}
+
+	results, err := sess.SQL(sql, args...).Query()
+	if err != nil {
+		return nil, err
+	}
+
+	foreignKeys := make([]inboundForeignKey, 0, len(results))
+	for _, row := range results {
+		foreignKeys = append(foreignKeys, inboundForeignKey{
+			schema:     string(row["schema"]),
+			tableName:  string(row["table_name"]),
+			name:       string(row["name"]),
+			definition: string(row["definition"]),
+		})
+	}
+	return foreignKeys, nil
+}
+
+func bothTablesExistCondition(childTable string, parentTable string) MigrationCondition {
+	return &AndCondition{Conditions: []MigrationCondition{
+		&IfTableExistsCondition{TableName: childTable},
+		&IfTableExistsCondition{TableName: parentTable},
+	}}
+}
+
+// InheritTableMigration makes childTable inherit from parentTable.
+type InheritTableMigration struct {
+	MigrationBase
+	childTable  string
+	parentTable string
+}
+
+func NewInheritTableMigration(childTable string, parentTable string) *InheritTableMigration {
+	m := &InheritTableMigration{childTable: childTable, parentTable: parentTable}
+	m.Condition = bothTablesExistCondition(childTable, parentTable)
+	return m
+}
+
+func (m *InheritTableMigration) SQL(d Dialect) string {
+	return d.InheritTableSql(m.childTable, m.parentTable)
+}
+
+func (m *InheritTableMigration) DownSQL(d Dialect) (string, error) {
+	return d.NoInheritTableSql(m.childTable, m.parentTable), nil
+}
+
+type NoInheritTableMigration struct {
+	MigrationBase
+	childTable  string
+	parentTable string
+}
+
+func NewNoInheritTableMigration(childTable string, parentTable string) *NoInheritTableMigration {
+	m := &NoInheritTableMigration{childTable: childTable, parentTable: parentTable}
+	m.Condition = bothTablesExistCondition(childTable, parentTable)
+	return m
+}
+
+func (m *NoInheritTableMigration) SQL(d Dialect) string {
+	return d.NoInheritTableSql(m.childTable, m.parentTable)
+}
+
+func (m *NoInheritTableMigration) DownSQL(d Dialect) (string, error) {
+	return d.InheritTableSql(m.childTable, m.parentTable), nil
+}
+
+type TableCharsetMigration struct {
+	MigrationBase
+	tableName string
+	columns   []*Column
+	database  *AlterDatabaseCharsetMigration
+}
+
+func NewTableCharsetMigration(tableName string, columns []*Column) *TableCharsetMigration {
+	return &TableCharsetMigration{tableName: tableName, columns: columns}
+}
+
+// WithDatabaseCharset changes the defaults of the database along with the table.
+func (m *TableCharsetMigration) WithDatabaseCharset(dbName string, charset string, collation string) *TableCharsetMigration {
+	m.database = NewAlterDatabaseCharsetMigration(dbName, charset, collation)
+	return m
+}
+
+func (m *TableCharsetMigration) SQL(d Dialect) string {
+	sql := d.UpdateTableSql(m.tableName, m.columns)
+	if m.database == nil {
+		return sql
+	}
+
+	statements := slices.DeleteFunc([]string{m.database.SQL(d), sql}, func(stmt string) bool { return stmt == d.NoOpSql() })
+	if len(statements) == 0 {
+		return d.NoOpSql()
+	}
+	return joinSql(statements...)
+}
+
+// AlterDatabaseCharsetMigration changes the default charset and collation of a database.
+type AlterDatabaseCharsetMigration struct {
+	MigrationBase
+	dbName    string
+	charset   string
+	collation string
+}
+
+func NewAlterDatabaseCharsetMigration(dbName string, charset string, collation string) *AlterDatabaseCharsetMigration {
+	return &AlterDatabaseCharsetMigration{dbName: dbName, charset: charset, collation: collation}
+}
+
+func (m *AlterDatabaseCharsetMigration) SQL(d Dialect) string {
+	return d.AlterDatabaseCharsetSql(m.dbName, m.charset, m.collation)
+}
+
+// AlterColumnTypeMigration changes column types, recreating the views depending on them.
+type AlterColumnTypeMigration struct {
+	MigrationBase
+	tableName string
+	columns   []*Column
+}
+
+func NewAlterColumnTypeMigration(table Table, columns []*Column) *AlterColumnTypeMigration {
+	return &AlterColumnTypeMigration{tableName: table.Name, columns: columns}
+}
+
+func (m *AlterColumnTypeMigration) SQL(d Dialect) string {
 	return d.UpdateTableSql(m.tableName, m.columns)
 }
 
+func (m *AlterColumnTypeMigration) Exec(sess *xorm.Session, mg *Migrator) error {
+	views, err := queryDependentViews(sess, mg.Dialect, m.tableName)
+	if err != nil {
+		return err
+	}
+
+	if err := views.drop(sess, mg.Dialect); err != nil {
+		return err
+	}
+
+	if _, err := sess.Exec(m.SQL(mg.Dialect)); err != nil {
+		return err
+	}
+
+	return views.create(sess, mg.Dialect)
+}
+
+type dependentView struct {
+	schema       string
+	name         string
+	materialized bool
+	definition   string
+}
+
+func (v dependentView) qualifiedName(d Dialect) string {
+	return d.Quote(v.schema) + "." + d.Quote(v.name)
+}
+
+func (v dependentView) kind() string {
+	if v.materialized {
+		return "MATERIALIZED VIEW"
+	}
+	return "VIEW"
+}
+
+type dependentViews []dependentView
+
+func queryDependentViews(sess *xorm.Session, d Dialect, tableName string) (dependentViews, error) {
+	sql, args := d.DependentViewsSql(tableName)
+	if sql == "" {
+		return nil, nil
+	}
+
+	results, err := sess.SQL(sql, args...).Query()
+	if err != nil {
+		return nil, err
+	}
+
+	views := make(dependentViews, 0, len(results))
+	for _, row := range results {
+		views = append(views, dependentView{
+			schema:       string(row["schema"]),
+			name:         string(row["name"]),
+			materialized: string(row["kind"]) == "m",
+			definition:   string(row["definition"]),
+		})
+	}
+	return views, nil
+}
+
+func (views dependentViews) drop(sess *xorm.Session, d Dialect) error {
+	for i := len(views) - 1; i >= 0; i-- {
+		v := views[i]
+		if _, err := sess.Exec(fmt.Sprintf("DROP %s %s", v.kind(), v.qualifiedName(d))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (views dependentViews) create(sess *xorm.Session, d Dialect) error {
+	for _, v := range views {
+		definition := strings.TrimSuffix(strings.TrimSpace(v.definition), ";")
+		if _, err := sess.Exec(fmt.Sprintf("CREATE %s %s AS %s", v.kind(), v.qualifiedName(d), definition)); err != nil {
+			return fmt.Errorf("failed to recreate view %s: %w", v.name, err)
+		}
+	}
+
+	return nil
+}
+
 type RenameColumnMigration struct {
 	MigrationBase
 	tableName string
@@ -238,18 +1240,1143 @@ func (m *RenameColumnMigration) SQL(d Dialect) string {
 	return d.RenameColumn(m.tableName, m.oldName, m.newName)
 }
 
+// RemoveColumnsMigration drops several columns of a table at once.
+type RemoveColumnsMigration struct {
+	MigrationBase
+	tableName   string
+	columnNames []string
+}
+
+func NewRemoveColumnsMigration(table Table, columnNames []string) *RemoveColumnsMigration {
+	return &RemoveColumnsMigration{tableName: table.Name, columnNames: columnNames}
+}
+
+func (m *RemoveColumnsMigration) Validate() error {
+	if len(m.columnNames) == 0 {
+		return fmt.Errorf("no columns to remove from %s", m.tableName)
+	}
+	return nil
+}
+
+func (m *RemoveColumnsMigration) Destructive() bool {
+	return true
+}
+
+func (m *RemoveColumnsMigration) SQL(d Dialect) string {
+	return d.DropColumnsIfExistSql(m.tableName, m.columnNames)
+}
+
 type RemoveColumnMigration struct {
 	MigrationBase
-	tableName string
-	columns   *Column
+	tableName    string
+	columns      *Column
+	primaryKeys  []*Column
+	archiveTable string
+	// IfExists makes the drop statement tolerate a missing column.
+	IfExists bool
 }
 
 func NewRemoveColumnMigration(table Table, columnName string) *RemoveColumnMigration {
-	return &RemoveColumnMigration{tableName: table.Name, columns: &Column{
+	m := &RemoveColumnMigration{tableName: table.Name, columns: &Column{
 		Name: columnName,
 	}}
+
+	for _, col := range table.Columns {
+		switch {
+		case col.Name == columnName:
+			m.columns = col
+		case col.IsPrimaryKey:
+			m.primaryKeys = append(m.primaryKeys, col)
+		}
+	}
+	return m
+}
+
+// ArchiveTo copies the column values into tableName before the column is dropped.
+func (m *RemoveColumnMigration) ArchiveTo(tableName string) *RemoveColumnMigration {
+	m.archiveTable = tableName
+	return m
+}
+
+// Validate refuses ArchiveTo without the primary key and the column definition.
+func (m *RemoveColumnMigration) Validate() error {
+	if m.archiveTable == "" {
+		return nil
+	}
+
+	if len(m.primaryKeys) == 0 {
+		return fmt.Errorf("cannot archive %s.%s without the primary key of the table", m.tableName, m.columns.Name)
+	}
+
+	if m.columns.Type == "" {
+		return fmt.Errorf("cannot archive %s.%s without the definition of the column", m.tableName, m.columns.Name)
+	}
+
+	return nil
+}
+
+// Destructive is true even with ArchiveTo, the column is gone for the table.
+func (m *RemoveColumnMigration) Destructive() bool {
+	return true
 }
 
 func (m *RemoveColumnMigration) SQL(d Dialect) string {
+	if m.archiveTable == "" {
+		return m.dropSql(d)
+	}
+
+	archive := Table{Name: m.archiveTable}
+	cols := make([]string, 0, len(m.primaryKeys)+1)
+	for _, pk := range m.primaryKeys {
+		col := *pk
+		col.IsAutoIncrement = false
+		archive.Columns = append(archive.Columns, &col)
+		cols = append(cols, col.Name)
+	}
+
+	col := *m.columns
+	col.IsPrimaryKey = false
+	col.IsAutoIncrement = false
+	col.Unique = false
+	col.Nullable = true
+	archive.Columns = append(archive.Columns, &col)
+	cols = append(cols, col.Name)
+
+	return joinSql(
+		NewAddTableMigration(archive).SQL(d),
+		d.CopyTableData(m.tableName, m.archiveTable, cols, cols),
+		m.dropSql(d),
+	)
+}
+
+func (m *RemoveColumnMigration) dropSql(d Dialect) string {
+	if m.IfExists {
+		return d.DropColumnIfExistsSql(m.tableName, m.columns)
+	}
+
 	return d.DropColumnSql(m.tableName, m.columns)
 }
+
+type AddPrimaryKeyMigration struct {
+	MigrationBase
+	tableName string
+	columns   []string
+}
+
+func NewAddPrimaryKeyMigration(table Table, columns []string) *AddPrimaryKeyMigration {
+	return &AddPrimaryKeyMigration{tableName: table.Name, columns: columns}
+}
+
+func (m *AddPrimaryKeyMigration) SQL(d Dialect) string {
+	return d.AddPrimaryKeySql(m.tableName, m.columns)
+}
+
+type DropPrimaryKeyMigration struct {
+	MigrationBase
+	tableName      string
+	constraintName string
+}
+
+// NewDropPrimaryKeyMigration drops the primary key, an empty name uses the default.
+func NewDropPrimaryKeyMigration(table Table, constraintName string) *DropPrimaryKeyMigration {
+	return &DropPrimaryKeyMigration{tableName: table.Name, constraintName: constraintName}
+}
+
+func (m *DropPrimaryKeyMigration) SQL(d Dialect) string {
+	return d.DropPrimaryKeySql(m.tableName, m.constraintName)
+}
+
+type DropConstraintMigration struct {
+	MigrationBase
+	tableName      string
+	constraintName string
+	ifExists       bool
+}
+
+func NewDropConstraintMigration(table Table, constraintName string) *DropConstraintMigration {
+	return &DropConstraintMigration{tableName: table.Name, constraintName: constraintName}
+}
+
+// IfExists tolerates a missing constraint.
+func (m *DropConstraintMigration) IfExists() *DropConstraintMigration {
+	m.ifExists = true
+	m.Condition = &IfConstraintExistsCondition{TableName: m.tableName, ConstraintName: m.constraintName}
+	return m
+}
+
+func (m *DropConstraintMigration) SQL(d Dialect) string {
+	return d.DropConstraintSql(m.tableName, m.constraintName, m.ifExists)
+}
+
+type DropForeignKeyMigration struct {
+	MigrationBase
+	tableName string
+	fkName    string
+	ifExists  bool
+}
+
+func NewDropForeignKeyMigration(table Table, fk *ForeignKey) *DropForeignKeyMigration {
+	return &DropForeignKeyMigration{tableName: table.Name, fkName: fk.XName(table.Name)}
+}
+
+// IfExists tolerates a missing foreign key, see DropConstraintMigration.IfExists.
+func (m *DropForeignKeyMigration) IfExists() *DropForeignKeyMigration {
+	m.ifExists = true
+	m.Condition = &IfConstraintExistsCondition{TableName: m.tableName, ConstraintName: m.fkName}
+	return m
+}
+
+func (m *DropForeignKeyMigration) SQL(d Dialect) string {
+	return d.DropForeignKeySql(m.tableName, m.fkName, m.ifExists)
+}
+
+type CommentOnIndexMigration struct {
+	MigrationBase
+	tableName string
+	indexName string
+	comment   string
+}
+
+func NewCommentOnIndexMigration(table Table, indexName string, comment string) *CommentOnIndexMigration {
+	return &CommentOnIndexMigration{tableName: table.Name, indexName: indexName, comment: comment}
+}
+
+func (m *CommentOnIndexMigration) SQL(d Dialect) string {
+	return d.CommentOnIndexSql(m.tableName, m.indexName, m.comment)
+}
+
+const redactedPassword = "********"
+
+type CreateRoleMigration struct {
+	MigrationBase
+	role Role
+}
+
+func NewCreateRoleMigration(name string) *CreateRoleMigration {
+	return &CreateRoleMigration{role: Role{Name: name}}
+}
+
+func (m *CreateRoleMigration) Login() *CreateRoleMigration {
+	m.role.Login = true
+	return m
+}
+
+func (m *CreateRoleMigration) Password(password string) *CreateRoleMigration {
+	m.role.Password = password
+	return m
+}
+
+func (m *CreateRoleMigration) InRole(parent string) *CreateRoleMigration {
+	m.role.InRoles = append(m.role.InRoles, parent)
+	return m
+}
+
+func (m *CreateRoleMigration) SQL(d Dialect) string {
+	return d.CreateRoleSql(&m.role)
+}
+
+func (m *CreateRoleMigration) RedactedSQL(d Dialect) string {
+	role := m.role
+	if role.Password != "" {
+		role.Password = redactedPassword
+	}
+
+	return d.CreateRoleSql(&role)
+}
+
+type CreateEventTriggerMigration struct {
+	MigrationBase
+	trigger EventTrigger
+}
+
+func NewCreateEventTriggerMigration(name string, event string, function string) *CreateEventTriggerMigration {
+	return &CreateEventTriggerMigration{trigger: EventTrigger{Name: name, Event: event, Function: function}}
+}
+
+// WhenTag limits the trigger to the given command tags, e.g. "DROP TABLE".
+func (m *CreateEventTriggerMigration) WhenTag(tags ...string) *CreateEventTriggerMigration {
+	m.trigger.Tags = append(m.trigger.Tags, tags...)
+	return m
+}
+
+func (m *CreateEventTriggerMigration) SQL(d Dialect) string {
+	return d.CreateEventTriggerSql(&m.trigger)
+}
+
+type DropEventTriggerMigration struct {
+	MigrationBase
+	name string
+}
+
+func NewDropEventTriggerMigration(name string) *DropEventTriggerMigration {
+	return &DropEventTriggerMigration{name: name}
+}
+
+func (m *DropEventTriggerMigration) SQL(d Dialect) string {
+	return d.DropEventTriggerSql(m.name)
+}
+
+// AlterEnumTypeMigration adds a value to an enum type.
+type AlterEnumTypeMigration struct {
+	MigrationBase
+	typeName string
+	value    string
+}
+
+func NewAlterEnumTypeMigration(typeName string, value string) *AlterEnumTypeMigration {
+	m := &AlterEnumTypeMigration{typeName: typeName, value: value}
+	m.Condition = &IfEnumValueNotExistsCondition{TypeName: typeName, Value: value}
+	return m
+}
+
+// SQL adds the value with IF NOT EXISTS where the server supports it.
+func (m *AlterEnumTypeMigration) SQL(d Dialect) string {
+	version, err := d.Version()
+	ifNotExists := err == nil && version >= 90300
+
+	return d.AddEnumValueSql(m.typeName, m.value, ifNotExists)
+}
+
+// NonTransactionalFor is true before Postgres 12 and when the version is unknown.
+func (m *AlterEnumTypeMigration) NonTransactionalFor(d Dialect) bool {
+	if d.DriverName() != POSTGRES {
+		return false
+	}
+
+	version, err := d.Version()
+	return err != nil || version < 120000
+}
+
+// CreateCastMigration creates a cast between two types.
+type CreateCastMigration struct {
+	MigrationBase
+	cast Cast
+}
+
+func NewCreateCastMigration(source string, target string, function string, context string) *CreateCastMigration {
+	return &CreateCastMigration{cast: Cast{Source: source, Target: target, Function: function, Context: context}}
+}
+
+func (m *CreateCastMigration) Validate() error {
+	switch m.cast.Context {
+	case CastExplicit, CastAssignment, CastImplicit:
+		return nil
+	}
+	return fmt.Errorf("unknown cast context %q", m.cast.Context)
+}
+
+func (m *CreateCastMigration) SQL(d Dialect) string {
+	return d.CreateCastSql(&m.cast)
+}
+
+func (m *CreateCastMigration) DownSQL(d Dialect) (string, error) {
+	return d.DropCastSql(m.cast.Source, m.cast.Target), nil
+}
+
+type DropCastMigration struct {
+	MigrationBase
+	source string
+	target string
+}
+
+func NewDropCastMigration(source string, target string) *DropCastMigration {
+	return &DropCastMigration{source: source, target: target}
+}
+
+func (m *DropCastMigration) SQL(d Dialect) string {
+	return d.DropCastSql(m.source, m.target)
+}
+
+type CreateAggregateMigration struct {
+	MigrationBase
+	aggregate Aggregate
+}
+
+func NewCreateAggregateMigration(name string, args []string, sfunc string, stype string) *CreateAggregateMigration {
+	return &CreateAggregateMigration{aggregate: Aggregate{Name: name, Args: args, SFunc: sfunc, SType: stype}}
+}
+
+// FinalFunc computes the result of the aggregate from its final state.
+func (m *CreateAggregateMigration) FinalFunc(function string) *CreateAggregateMigration {
+	m.aggregate.FinalFunc = function
+	return m
+}
+
+// InitCond is the initial state, as a literal of the state type.
+func (m *CreateAggregateMigration) InitCond(value string) *CreateAggregateMigration {
+	m.aggregate.InitCond = value
+	return m
+}
+
+// OrReplace replaces an existing aggregate, which requires Postgres 12.
+func (m *CreateAggregateMigration) OrReplace() *CreateAggregateMigration {
+	m.aggregate.OrReplace = true
+	return m
+}
+
+func (m *CreateAggregateMigration) SQL(d Dialect) string {
+	return d.CreateAggregateSql(&m.aggregate)
+}
+
+func (m *CreateAggregateMigration) DownSQL(d Dialect) (string, error) {
+	return d.DropAggregateSql(m.aggregate.Name, m.aggregate.Args), nil
+}
+
+type DropAggregateMigration struct {
+	MigrationBase
+	name string
+	args []string
+}
+
+func NewDropAggregateMigration(name string, args []string) *DropAggregateMigration {
+	return &DropAggregateMigration{name: name, args: args}
+}
+
+func (m *DropAggregateMigration) SQL(d Dialect) string {
+	return d.DropAggregateSql(m.name, m.args)
+}
+
+// CreateTextSearchConfigMigration creates a text search configuration.
+type CreateTextSearchConfigMigration struct {
+	MigrationBase
+	name     string
+	copyFrom string
+	parser   string
+}
+
+// NewCreateTextSearchConfigMigration copies copyFrom, e.g. pg_catalog.german.
+func NewCreateTextSearchConfigMigration(name string, copyFrom string) *CreateTextSearchConfigMigration {
+	return &CreateTextSearchConfigMigration{name: name, copyFrom: copyFrom}
+}
+
+// Parser starts the configuration from the parser instead of a copy.
+func (m *CreateTextSearchConfigMigration) Parser(parser string) *CreateTextSearchConfigMigration {
+	m.parser = parser
+	return m
+}
+
+// Validate refuses both or neither of a configuration to copy and a parser.
+func (m *CreateTextSearchConfigMigration) Validate() error {
+	if (m.copyFrom == "") == (m.parser == "") {
+		return fmt.Errorf("text search configuration %s needs either a configuration to copy or a parser", m.name)
+	}
+	return nil
+}
+
+func (m *CreateTextSearchConfigMigration) SQL(d Dialect) string {
+	return d.CreateTextSearchConfigSql(m.name, m.copyFrom, m.parser)
+}
+
+func (m *CreateTextSearchConfigMigration) DownSQL(d Dialect) (string, error) {
+	return d.DropTextSearchConfigSql(m.name), nil
+}
+
+// AlterTextSearchConfigMigration maps token types to dictionaries.
+type AlterTextSearchConfigMigration struct {
+	MigrationBase
+	name         string
+	tokenTypes   []string
+	dictionaries []string
+	add          bool
+}
+
+// NewAlterTextSearchConfigMigration replaces the existing mapping of the token types.
+func NewAlterTextSearchConfigMigration(name string, tokenTypes []string, dictionaries []string) *AlterTextSearchConfigMigration {
+	return &AlterTextSearchConfigMigration{name: name, tokenTypes: tokenTypes, dictionaries: dictionaries}
+}
+
+// AddMapping adds a mapping for token types that have none yet.
+func (m *AlterTextSearchConfigMigration) AddMapping() *AlterTextSearchConfigMigration {
+	m.add = true
+	return m
+}
+
+func (m *AlterTextSearchConfigMigration) SQL(d Dialect) string {
+	return d.AlterTextSearchMappingSql(m.name, m.tokenTypes, m.dictionaries, m.add)
+}
+
+type DropTextSearchConfigMigration struct {
+	MigrationBase
+	name string
+}
+
+func NewDropTextSearchConfigMigration(name string) *DropTextSearchConfigMigration {
+	return &DropTextSearchConfigMigration{name: name}
+}
+
+func (m *DropTextSearchConfigMigration) SQL(d Dialect) string {
+	return d.DropTextSearchConfigSql(m.name)
+}
+
+func requirePostgres(d Dialect, feature string) error {
+	if d.DriverName() != POSTGRES {
+		return fmt.Errorf("%s is only supported on %s, not %s", feature, POSTGRES, d.DriverName())
+	}
+	return nil
+}
+
+type CreatePublicationMigration struct {
+	MigrationBase
+	name   string
+	tables []string
+}
+
+// NewCreatePublicationMigration publishes the tables, or none when tables is empty.
+func NewCreatePublicationMigration(name string, tables ...string) *CreatePublicationMigration {
+	return &CreatePublicationMigration{name: name, tables: append([]string{}, tables...)}
+}
+
+// AllTables publishes every table, including tables created later.
+func (m *CreatePublicationMigration) AllTables() *CreatePublicationMigration {
+	m.tables = nil
+	return m
+}
+
+func (m *CreatePublicationMigration) ValidateDialect(d Dialect) error {
+	return requirePostgres(d, "CREATE PUBLICATION")
+}
+
+func (m *CreatePublicationMigration) SQL(d Dialect) string {
+	return d.CreatePublicationSql(m.name, m.tables)
+}
+
+func (m *CreatePublicationMigration) DownSQL(d Dialect) (string, error) {
+	return d.DropPublicationSql(m.name), nil
+}
+
+type AddTableToPublicationMigration struct {
+	MigrationBase
+	publication string
+	table       string
+}
+
+func NewAddTableToPublicationMigration(publication string, table string) *AddTableToPublicationMigration {
+	return &AddTableToPublicationMigration{publication: publication, table: table}
+}
+
+func (m *AddTableToPublicationMigration) ValidateDialect(d Dialect) error {
+	return requirePostgres(d, "ALTER PUBLICATION")
+}
+
+func (m *AddTableToPublicationMigration) SQL(d Dialect) string {
+	return d.AddTableToPublicationSql(m.publication, m.table)
+}
+
+func (m *AddTableToPublicationMigration) DownSQL(d Dialect) (string, error) {
+	return d.DropTableFromPublicationSql(m.publication, m.table), nil
+}
+
+type DropTableFromPublicationMigration struct {
+	MigrationBase
+	publication string
+	table       string
+}
+
+func NewDropTableFromPublicationMigration(publication string, table string) *DropTableFromPublicationMigration {
+	return &DropTableFromPublicationMigration{publication: publication, table: table}
+}
+
+func (m *DropTableFromPublicationMigration) ValidateDialect(d Dialect) error {
+	return requirePostgres(d, "ALTER PUBLICATION")
+}
+
+func (m *DropTableFromPublicationMigration) SQL(d Dialect) string {
+	return d.DropTableFromPublicationSql(m.publication, m.table)
+}
+
+func (m *DropTableFromPublicationMigration) DownSQL(d Dialect) (string, error) {
+	return d.AddTableToPublicationSql(m.publication, m.table), nil
+}
+
+type CreateOperatorMigration struct {
+	MigrationBase
+	operator Operator
+}
+
+// NewCreateOperatorMigration creates a prefix operator when leftArg is empty.
+func NewCreateOperatorMigration(name string, leftArg string, rightArg string, function string) *CreateOperatorMigration {
+	return &CreateOperatorMigration{operator: Operator{Name: name, LeftArg: leftArg, RightArg: rightArg, Function: function}}
+}
+
+// Commutator is the operator giving the same result with swapped operands.
+func (m *CreateOperatorMigration) Commutator(operator string) *CreateOperatorMigration {
+	m.operator.Commutator = operator
+	return m
+}
+
+// Negator is the operator giving the opposite result, which helps the planner.
+func (m *CreateOperatorMigration) Negator(operator string) *CreateOperatorMigration {
+	m.operator.Negator = operator
+	return m
+}
+
+func (m *CreateOperatorMigration) SQL(d Dialect) string {
+	return d.CreateOperatorSql(&m.operator)
+}
+
+func (m *CreateOperatorMigration) DownSQL(d Dialect) (string, error) {
+	return d.DropOperatorSql(m.operator.Name, m.operator.LeftArg, m.operator.RightArg), nil
+}
+
+type DropOperatorMigration struct {
+	MigrationBase
+	name     string
+	leftArg  string
+	rightArg string
+}
+
+func NewDropOperatorMigration(name string, leftArg string, rightArg string) *DropOperatorMigration {
+	return &DropOperatorMigration{name: name, leftArg: leftArg, rightArg: rightArg}
+}
+
+func (m *DropOperatorMigration) SQL(d Dialect) string {
+	return d.DropOperatorSql(m.name, m.leftArg, m.rightArg)
+}
+
+type CreateOperatorClassMigration struct {
+	MigrationBase
+	opClass OperatorClass
+}
+
+// NewCreateOperatorClassMigration creates an operator class for dataType and method.
+func NewCreateOperatorClassMigration(name string, dataType string, method string) *CreateOperatorClassMigration {
+	return &CreateOperatorClassMigration{opClass: OperatorClass{Name: name, Type: dataType, Method: method}}
+}
+
+// Default makes the operator class the default for the type.
+func (m *CreateOperatorClassMigration) Default() *CreateOperatorClassMigration {
+	m.opClass.Default = true
+	return m
+}
+
+func (m *CreateOperatorClassMigration) Operator(strategy int, operator string) *CreateOperatorClassMigration {
+	m.opClass.Members = append(m.opClass.Members, OperatorClassMember{Number: strategy, Operator: operator})
+	return m
+}
+
+// Function adds a support function with its argument types, e.g. "cmp(a, a)".
+func (m *CreateOperatorClassMigration) Function(support int, function string) *CreateOperatorClassMigration {
+	m.opClass.Members = append(m.opClass.Members, OperatorClassMember{Number: support, Function: function})
+	return m
+}
+
+func (m *CreateOperatorClassMigration) Storage(dataType string) *CreateOperatorClassMigration {
+	m.opClass.Storage = dataType
+	return m
+}
+
+// Validate refuses an operator class without operators or functions.
+func (m *CreateOperatorClassMigration) Validate() error {
+	if len(m.opClass.Members) == 0 {
+		return fmt.Errorf("operator class %s has no operators or functions", m.opClass.Name)
+	}
+	return nil
+}
+
+func (m *CreateOperatorClassMigration) SQL(d Dialect) string {
+	return d.CreateOperatorClassSql(&m.opClass)
+}
+
+func (m *CreateOperatorClassMigration) DownSQL(d Dialect) (string, error) {
+	return d.DropOperatorClassSql(m.opClass.Name, m.opClass.Method), nil
+}
+
+type DropOperatorClassMigration struct {
+	MigrationBase
+	name   string
+	method string
+}
+
+func NewDropOperatorClassMigration(name string, method string) *DropOperatorClassMigration {
+	return &DropOperatorClassMigration{name: name, method: method}
+}
+
+func (m *DropOperatorClassMigration) SQL(d Dialect) string {
+	return d.DropOperatorClassSql(m.name, m.method)
+}
+
+type SetReplicaIdentityMigration struct {
+	MigrationBase
+	tableName string
+	identity  ReplicaIdentity
+}
+
+func NewSetReplicaIdentityMigration(table Table, identity ReplicaIdentity) *SetReplicaIdentityMigration {
+	return &SetReplicaIdentityMigration{tableName: table.Name, identity: identity}
+}
+
+func (m *SetReplicaIdentityMigration) SQL(d Dialect) string {
+	return d.SetReplicaIdentitySql(m.tableName, m.identity)
+}
+
+// LockTableMigration locks a table until the transaction commits.
+type LockTableMigration struct {
+	MigrationBase
+	tableName string
+	mode      LockMode
+}
+
+func NewLockTableMigration(table Table, mode LockMode) *LockTableMigration {
+	return &LockTableMigration{tableName: table.Name, mode: mode}
+}
+
+func (m *LockTableMigration) Validate() error {
+	if !slices.Contains(lockModes, m.mode) {
+		return fmt.Errorf("unknown lock mode %q", m.mode)
+	}
+	return nil
+}
+
+func (m *LockTableMigration) SQL(d Dialect) string {
+	return d.LockTableSql(m.tableName, m.mode)
+}
+
+type SetTableLoggedMigration struct {
+	MigrationBase
+	tableName string
+	logged    bool
+}
+
+func NewSetTableLoggedMigration(tableName string, logged bool) *SetTableLoggedMigration {
+	return &SetTableLoggedMigration{tableName: tableName, logged: logged}
+}
+
+func (m *SetTableLoggedMigration) SQL(d Dialect) string {
+	return d.SetTableLoggedSql(m.tableName, m.logged)
+}
+
+type EnableRLSMigration struct {
+	MigrationBase
+	tableName string
+}
+
+func NewEnableRLSMigration(table Table) *EnableRLSMigration {
+	return &EnableRLSMigration{tableName: table.Name}
+}
+
+func (m *EnableRLSMigration) SQL(d Dialect) string {
+	return d.EnableRowLevelSecuritySql(m.tableName, true)
+}
+
+type DisableRLSMigration struct {
+	MigrationBase
+	tableName string
+}
+
+func NewDisableRLSMigration(table Table) *DisableRLSMigration {
+	return &DisableRLSMigration{tableName: table.Name}
+}
+
+func (m *DisableRLSMigration) SQL(d Dialect) string {
+	return d.EnableRowLevelSecuritySql(m.tableName, false)
+}
+
+// ForceRLSMigration applies the row level security policies to the table owner too.
+type ForceRLSMigration struct {
+	MigrationBase
+	tableName string
+}
+
+func NewForceRLSMigration(table Table) *ForceRLSMigration {
+	return &ForceRLSMigration{tableName: table.Name}
+}
+
+func (m *ForceRLSMigration) SQL(d Dialect) string {
+	return d.ForceRowLevelSecuritySql(m.tableName, true)
+}
+
+type NoForceRLSMigration struct {
+	MigrationBase
+	tableName string
+}
+
+func NewNoForceRLSMigration(table Table) *NoForceRLSMigration {
+	return &NoForceRLSMigration{tableName: table.Name}
+}
+
+func (m *NoForceRLSMigration) SQL(d Dialect) string {
+	return d.ForceRowLevelSecuritySql(m.tableName, false)
+}
+
+type AlterTableOwnerMigration struct {
+	MigrationBase
+	tableName string
+	owner     string
+}
+
+func NewAlterTableOwnerMigration(table Table, owner string) *AlterTableOwnerMigration {
+	return &AlterTableOwnerMigration{tableName: table.Name, owner: owner}
+}
+
+func (m *AlterTableOwnerMigration) SQL(d Dialect) string {
+	return d.AlterTableOwnerSql(m.tableName, m.owner)
+}
+
+type AlterSequenceOwnerMigration struct {
+	MigrationBase
+	sequenceName string
+	owner        string
+}
+
+func NewAlterSequenceOwnerMigration(sequenceName string, owner string) *AlterSequenceOwnerMigration {
+	return &AlterSequenceOwnerMigration{sequenceName: sequenceName, owner: owner}
+}
+
+func (m *AlterSequenceOwnerMigration) SQL(d Dialect) string {
+	return d.AlterSequenceOwnerSql(m.sequenceName, m.owner)
+}
+
+type AlterViewOwnerMigration struct {
+	MigrationBase
+	viewName string
+	owner    string
+}
+
+func NewAlterViewOwnerMigration(viewName string, owner string) *AlterViewOwnerMigration {
+	return &AlterViewOwnerMigration{viewName: viewName, owner: owner}
+}
+
+func (m *AlterViewOwnerMigration) SQL(d Dialect) string {
+	return d.AlterViewOwnerSql(m.viewName, m.owner)
+}
+
+type CreateSchemaMigration struct {
+	MigrationBase
+	name string
+}
+
+func NewCreateSchemaMigration(name string) *CreateSchemaMigration {
+	return &CreateSchemaMigration{name: name}
+}
+
+func (m *CreateSchemaMigration) SQL(d Dialect) string {
+	return d.CreateSchemaSql(m.name)
+}
+
+type DropSchemaMigration struct {
+	MigrationBase
+	name    string
+	cascade bool
+}
+
+// NewDropSchemaMigration drops the schema, with cascade also its objects.
+func NewDropSchemaMigration(name string, cascade bool) *DropSchemaMigration {
+	return &DropSchemaMigration{name: name, cascade: cascade}
+}
+
+func (m *DropSchemaMigration) Destructive() bool {
+	return true
+}
+
+func (m *DropSchemaMigration) check(sess *xorm.Session, d Dialect) error {
+	if m.cascade {
+		return nil
+	}
+
+	results, err := sess.SQL("SELECT 1 FROM information_schema.tables WHERE table_schema = ?"+d.Limit(1), m.name).Query()
+	if err != nil {
+		return err
+	}
+
+	if len(results) > 0 {
+		return fmt.Errorf("schema %s is not empty, drop it with cascade to drop its tables as well", m.name)
+	}
+
+	return nil
+}
+
+func (m *DropSchemaMigration) SQL(d Dialect) string {
+	return d.DropSchemaSql(m.name, m.cascade)
+}
+
+// SetSearchPathMigration sets the search path of the migrations registered after it.
+type SetSearchPathMigration struct {
+	MigrationBase
+	schemas []string
+}
+
+func NewSetSearchPathMigration(schemas ...string) *SetSearchPathMigration {
+	return &SetSearchPathMigration{schemas: schemas}
+}
+
+func (m *SetSearchPathMigration) SQL(d Dialect) string {
+	return d.NoOpSql()
+}
+
+const (
+	StatisticsNDistinct    = "ndistinct"
+	StatisticsDependencies = "dependencies"
+	StatisticsMCV          = "mcv"
+)
+
+type CreateStatisticsMigration struct {
+	MigrationBase
+	name      string
+	tableName string
+	columns   []string
+	kinds     []string
+}
+
+func NewCreateStatisticsMigration(name string, table Table, columns []string, kinds ...string) *CreateStatisticsMigration {
+	m := &CreateStatisticsMigration{name: name, tableName: table.Name, columns: columns, kinds: kinds}
+	m.Condition = &IfStatisticsNotExistsCondition{Name: name}
+	return m
+}
+
+func (m *CreateStatisticsMigration) SQL(d Dialect) string {
+	return d.CreateStatisticsSql(m.name, m.tableName, m.columns, m.kinds)
+}
+
+type DropStatisticsMigration struct {
+	MigrationBase
+	name string
+}
+
+func NewDropStatisticsMigration(name string) *DropStatisticsMigration {
+	return &DropStatisticsMigration{name: name}
+}
+
+func (m *DropStatisticsMigration) SQL(d Dialect) string {
+	return d.DropStatisticsSql(m.name)
+}
+
+// RefreshMaterializedViewMigration refreshes a materialized view.
+type RefreshMaterializedViewMigration struct {
+	MigrationBase
+	viewName     string
+	Concurrently bool
+}
+
+func NewRefreshMaterializedViewMigration(viewName string) *RefreshMaterializedViewMigration {
+	return &RefreshMaterializedViewMigration{viewName: viewName}
+}
+
+func (m *RefreshMaterializedViewMigration) SQL(d Dialect) string {
+	return d.RefreshMaterializedViewSql(m.viewName, m.Concurrently)
+}
+
+func (m *RefreshMaterializedViewMigration) NonTransactional() bool {
+	return m.Concurrently
+}
+
+// ClusterTableMigration orders a table by an index, outside of a transaction.
+type ClusterTableMigration struct {
+	MigrationBase
+	tableName string
+	indexName string
+}
+
+func NewClusterTableMigration(table Table, indexName string) *ClusterTableMigration {
+	return &ClusterTableMigration{tableName: table.Name, indexName: indexName}
+}
+
+func (m *ClusterTableMigration) SQL(d Dialect) string {
+	return d.ClusterTableSql(m.tableName, m.indexName)
+}
+
+func (m *ClusterTableMigration) NonTransactional() bool {
+	return true
+}
+
+// UpsertDataMigration inserts rows and updates the ones colliding on the conflict columns.
+type UpsertDataMigration struct {
+	MigrationBase
+	tableName    string
+	cols         []string
+	conflictCols []string
+	updateCols   []string
+	rows         [][]interface{}
+}
+
+func NewUpsertDataMigration(tableName string, cols []string, conflictCols []string, updateCols []string) *UpsertDataMigration {
+	return &UpsertDataMigration{tableName: tableName, cols: cols, conflictCols: conflictCols, updateCols: updateCols}
+}
+
+// Values adds a row, with one value per column.
+func (m *UpsertDataMigration) Values(values ...interface{}) *UpsertDataMigration {
+	m.rows = append(m.rows, values)
+	return m
+}
+
+func (m *UpsertDataMigration) SQL(d Dialect) string {
+	return d.BulkUpsertSql(m.tableName, m.cols, m.conflictCols, m.updateCols, 1)
+}
+
+// Validate refuses incomplete rows and rows repeating a conflict key.
+func (m *UpsertDataMigration) Validate() error {
+	if len(m.cols) == 0 {
+		return fmt.Errorf("no columns to upsert into %s", m.tableName)
+	}
+
+	if len(m.conflictCols) == 0 {
+		return fmt.Errorf("no conflict columns to upsert into %s", m.tableName)
+	}
+
+	keyIndexes := make([]int, 0, len(m.conflictCols))
+	for _, col := range m.conflictCols {
+		i := slices.Index(m.cols, col)
+		if i < 0 {
+			return fmt.Errorf("conflict column %s is not upserted into %s", col, m.tableName)
+		}
+		keyIndexes = append(keyIndexes, i)
+	}
+
+	seen := make(map[string]int, len(m.rows))
+	for i, row := range m.rows {
+		if len(row) != len(m.cols) {
+			return fmt.Errorf("row %d of %s has %d values for %d columns", i+1, m.tableName, len(row), len(m.cols))
+		}
+
+		key := make([]interface{}, 0, len(keyIndexes))
+		for _, j := range keyIndexes {
+			key = append(key, row[j])
+		}
+		if first, ok := seen[fmt.Sprint(key...)]; ok {
+			return fmt.Errorf("rows %d and %d of %s have the same conflict key", first+1, i+1, m.tableName)
+		}
+		seen[fmt.Sprint(key...)] = i
+	}
+
+	return nil
+}
+
+func (m *UpsertDataMigration) Exec(sess *xorm.Session, mg *Migrator) error {
+	for batch := range slices.Chunk(m.rows, bulkBatchSize(len(m.cols))) {
+		args := make([]interface{}, 0, 1+len(batch)*len(m.cols))
+		args = append(args, mg.Dialect.BulkUpsertSql(m.tableName, m.cols, m.conflictCols, m.updateCols, len(batch)))
+		for _, row := range batch {
+			args = append(args, row...)
+		}
+		if _, err := sess.Exec(args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+const (
+	bulkLoadBatchSize = 1000
+	maxBindParams     = 65535
+)
+
+func bulkBatchSize(colCount int) int {
+	return min(bulkLoadBatchSize, maxBindParams/colCount)
+}
+
+// BulkLoadMigration loads seed data, through COPY on Postgres.
+type BulkLoadMigration struct {
+	MigrationBase
+	tableName string
+	cols      []string
+	rows      [][]interface{}
+}
+
+func NewBulkLoadMigration(tableName string, cols []string, rows [][]interface{}) *BulkLoadMigration {
+	return &BulkLoadMigration{tableName: tableName, cols: cols, rows: rows}
+}
+
+func (m *BulkLoadMigration) SQL(d Dialect) string {
+	if d.DriverName() == POSTGRES {
+		return pq.CopyIn(m.tableName, m.cols...)
+	}
+	return d.BulkInsertSql(m.tableName, m.cols, 1)
+}
+
+// Validate refuses rows that do not have a value for every column.
+func (m *BulkLoadMigration) Validate() error {
+	if len(m.cols) == 0 {
+		return fmt.Errorf("bulk load into %s has no columns", m.tableName)
+	}
+
+	for i, row := range m.rows {
+		if len(row) != len(m.cols) {
+			return fmt.Errorf("row %d of %s has %d values for %d columns", i+1, m.tableName, len(row), len(m.cols))
+		}
+	}
+
+	return nil
+}
+
+func (m *BulkLoadMigration) Exec(sess *xorm.Session, mg *Migrator) error {
+	if mg.Dialect.DriverName() == POSTGRES {
+		return m.copyIn(sess, mg.Dialect)
+	}
+
+	for batch := range slices.Chunk(m.rows, bulkBatchSize(len(m.cols))) {
+		args := make([]interface{}, 0, 1+len(batch)*len(m.cols))
+		args = append(args, mg.Dialect.BulkInsertSql(m.tableName, m.cols, len(batch)))
+		for _, row := range batch {
+			args = append(args, row...)
+		}
+		if _, err := sess.Exec(args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *BulkLoadMigration) copyIn(sess *xorm.Session, d Dialect) error {
+	tx := sess.Tx()
+	if tx == nil {
+		return fmt.Errorf("bulk load into %s has to run in a transaction", m.tableName)
+	}
+
+	stmt, err := tx.Tx.Prepare(m.SQL(d))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = stmt.Close() }()
+
+	for _, row := range m.rows {
+		if _, err := stmt.Exec(row...); err != nil {
+			return err
+		}
+	}
+
+	_, err = stmt.Exec()
+	return err
+}
+
+// ResetDatabaseMigration removes all data while keeping the schema.
+type ResetDatabaseMigration struct {
+	MigrationBase
+}
+
+func NewResetDatabaseMigration() *ResetDatabaseMigration {
+	return &ResetDatabaseMigration{}
+}
+
+func (m *ResetDatabaseMigration) SQL(d Dialect) string {
+	return d.NoOpSql()
+}
+
+func (m *ResetDatabaseMigration) Destructive() bool {
+	return true
+}
+
+func (m *ResetDatabaseMigration) Exec(sess *xorm.Session, mg *Migrator) error {
+	sql, args := mg.Dialect.TablesSql()
+	results, err := sess.SQL(sql, args...).Query()
+	if err != nil {
+		return err
+	}
+
+	tableNames := make([]string, 0, len(results))
+	for _, row := range results {
+		name := string(row["tablename"])
+		if name == "migration_log" || name == checkpointTable.Name {
+			continue
+		}
+		tableNames = append(tableNames, name)
+	}
+
+	truncateSql := mg.Dialect.TruncateTablesSql(tableNames)
+	if truncateSql == "" {
+		return nil
+	}
+
+	_, err = sess.Exec(truncateSql)
+	return err
+}
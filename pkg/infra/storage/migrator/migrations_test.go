@@ -0,0 +1,752 @@
+package migrator
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenameTableMigration(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewPostgresDialect(nil)
+	table := Table{
+		Name: "user",
+		Columns: []*Column{
+			{Name: "id", Type: DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "login_name", Type: DB_NVarchar, Length: 255},
+		},
+		Indices: []*Index{
+			{Cols: []string{"login_name"}, Type: UniqueIndex},
+		},
+	}
+
+	m := NewRenameTableMigration("user", "account")
+	assert.Equal(`ALTER TABLE "user" RENAME TO "account"`, m.SQL(d))
+
+	m.WithDependents(table)
+	assert.Equal(`ALTER TABLE "user" RENAME TO "account";
+ALTER INDEX IF EXISTS "UQE_user_login_name" RENAME TO "UQE_account_login_name";
+ALTER SEQUENCE IF EXISTS "user_id_seq" RENAME TO "account_id_seq"`, m.SQL(d))
+}
+
+func TestRemoveColumnMigrationArchiveTo(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewPostgresDialect(nil)
+	table := Table{
+		Name: "user",
+		Columns: []*Column{
+			{Name: "id", Type: DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "email", Type: DB_NVarchar, Length: 255, Nullable: false},
+		},
+	}
+
+	m := NewRemoveColumnMigration(table, "email").ArchiveTo("user_email_archive")
+	assert.NoError(m.Validate())
+	assert.Equal(`CREATE TABLE IF NOT EXISTS "user_email_archive" (
+"id" BIGINT PRIMARY KEY NOT NULL
+, "email" VARCHAR(255) NULL
+);
+INSERT INTO "user_email_archive" ("id"
+, "email") SELECT "id"
+, "email" FROM "user";
+ALTER TABLE "user" DROP COLUMN "email"`, m.SQL(d))
+
+	assert.NoError(NewRemoveColumnMigration(Table{Name: "user"}, "email").Validate())
+	assert.Error(NewRemoveColumnMigration(Table{Name: "user"}, "email").ArchiveTo("user_email_archive").Validate())
+	assert.Error(NewRemoveColumnMigration(Table{Name: "user", Columns: table.Columns[1:]}, "email").ArchiveTo("user_email_archive").Validate())
+	assert.Error(NewRemoveColumnMigration(Table{Name: "user", Columns: table.Columns[:1]}, "email").ArchiveTo("user_email_archive").Validate())
+}
+
+func TestRemoveColumnMigrationIfExists(t *testing.T) {
+	d := NewPostgresDialect(nil)
+
+	m := NewRemoveColumnMigration(Table{Name: "user"}, "email")
+	m.IfExists = true
+	assert.Equal(t, `ALTER TABLE "user" DROP COLUMN IF EXISTS "email"`, m.SQL(d))
+}
+
+func TestPrimaryKeyMigrations(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewPostgresDialect(nil)
+	table := Table{Name: "user_role"}
+
+	assert.Equal(`ALTER TABLE "user_role" ADD PRIMARY KEY ("user_id", "role_id")`,
+		NewAddPrimaryKeyMigration(table, []string{"user_id", "role_id"}).SQL(d))
+	assert.Equal(`ALTER TABLE "user_role" DROP CONSTRAINT "user_role_pkey"`,
+		NewDropPrimaryKeyMigration(table, "").SQL(d))
+	assert.Equal(`ALTER TABLE "user_role" DROP CONSTRAINT "pk_user_role"`,
+		NewDropPrimaryKeyMigration(table, "pk_user_role").SQL(d))
+}
+
+func TestAddTableMigrationForeignKeys(t *testing.T) {
+	d := NewPostgresDialect(nil)
+	table := Table{
+		Name: "order",
+		Columns: []*Column{
+			{Name: "id", Type: DB_BigInt, IsPrimaryKey: true},
+			{Name: "user_id", Type: DB_BigInt},
+		},
+		ForeignKeys: []ForeignKey{
+			{Cols: []string{"user_id"}, RefTable: "user", RefCols: []string{"id"}, OnDelete: ForeignKeyCascade},
+		},
+	}
+
+	assert.Equal(t, `CREATE TABLE IF NOT EXISTS "order" (
+"id" BIGINT PRIMARY KEY NOT NULL
+, "user_id" BIGINT NOT NULL
+, CONSTRAINT "FK_order_user_id" FOREIGN KEY ("user_id") REFERENCES "user" ("id") ON DELETE CASCADE)`, NewAddTableMigration(table).SQL(d))
+}
+
+func TestAddTableMigrationCheckConstraints(t *testing.T) {
+	d := NewPostgresDialect(nil)
+	table := Table{
+		Name: "product",
+		Columns: []*Column{
+			{Name: "id", Type: DB_BigInt, IsPrimaryKey: true},
+			{Name: "price", Type: DB_BigInt},
+		},
+		CheckConstraints: []CheckConstraint{
+			{Name: "product_price_positive", Expr: "price > 0"},
+			{Expr: "price < 1000000"},
+		},
+	}
+
+	assert.Equal(t, `CREATE TABLE IF NOT EXISTS "product" (
+"id" BIGINT PRIMARY KEY NOT NULL
+, "price" BIGINT NOT NULL
+, CONSTRAINT "product_price_positive" CHECK (price > 0), CHECK (price < 1000000))`, NewAddTableMigration(table).SQL(d))
+}
+
+func TestAddTableMigrationStorageParams(t *testing.T) {
+	d := NewPostgresDialect(nil)
+	table := Table{
+		Name: "session",
+		Columns: []*Column{
+			{Name: "id", Type: DB_BigInt, IsPrimaryKey: true},
+		},
+		StorageParams: map[string]string{"fillfactor": "70", "autovacuum_enabled": "true"},
+	}
+
+	assert.Equal(t, `CREATE TABLE IF NOT EXISTS "session" (
+"id" BIGINT PRIMARY KEY NOT NULL
+) WITH (autovacuum_enabled=true, fillfactor=70)`, NewAddTableMigration(table).SQL(d))
+}
+
+func TestAddTableMigrationColumnCheck(t *testing.T) {
+	d := NewPostgresDialect(nil)
+	table := Table{
+		Name: "account",
+		Columns: []*Column{
+			{Name: "id", Type: DB_BigInt, IsPrimaryKey: true},
+			{Name: "status", Type: DB_NVarchar, Length: 20, Unique: true, Check: "status IN ('active', 'disabled')"},
+		},
+	}
+
+	assert.Equal(t, `CREATE TABLE IF NOT EXISTS "account" (
+"id" BIGINT PRIMARY KEY NOT NULL
+, "status" VARCHAR(20) NOT NULL CHECK (status IN ('active', 'disabled'))
+, UNIQUE ( "status" ))`, NewAddTableMigration(table).SQL(d))
+}
+
+func TestAddTableMigrationRowSecurityEnabled(t *testing.T) {
+	d := NewPostgresDialect(nil)
+	table := Table{
+		Name: "document",
+		Columns: []*Column{
+			{Name: "id", Type: DB_BigInt, IsPrimaryKey: true},
+		},
+		RowSecurityEnabled: true,
+	}
+
+	assert.Equal(t, `CREATE TABLE IF NOT EXISTS "document" (
+"id" BIGINT PRIMARY KEY NOT NULL
+);
+ALTER TABLE "document" ENABLE ROW LEVEL SECURITY`, NewAddTableMigration(table).SQL(d))
+}
+
+func TestCommentOnIndexMigration(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewPostgresDialect(nil)
+	table := Table{Name: "user"}
+
+	assert.Equal(`COMMENT ON INDEX "UQE_user_login" IS 'Login names can''t repeat'`,
+		NewCommentOnIndexMigration(table, "UQE_user_login", "Login names can't repeat").SQL(d))
+	assert.Equal(`COMMENT ON INDEX "UQE_user_login" IS NULL`,
+		NewCommentOnIndexMigration(table, "UQE_user_login", "").SQL(d))
+}
+
+func TestCreateRoleMigration(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewPostgresDialect(nil)
+	m := NewCreateRoleMigration("app").Login().Password("s3cr'et").InRole("readers")
+
+	assert.Equal(`DO $$
+BEGIN
+	IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = 'app') THEN
+		CREATE ROLE "app" LOGIN PASSWORD 's3cr''et' IN ROLE "readers";
+	END IF;
+END
+$$`, m.SQL(d))
+	assert.NotContains(m.RedactedSQL(d), "s3cr")
+	assert.Contains(m.RedactedSQL(d), `PASSWORD '********'`)
+}
+
+func TestAlterOwnerMigrations(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewPostgresDialect(nil)
+
+	assert.Equal(`ALTER TABLE "user" OWNER TO "app"`, NewAlterTableOwnerMigration(Table{Name: "user"}, "app").SQL(d))
+	assert.Equal(`ALTER SEQUENCE "user_id_seq" OWNER TO "app"`, NewAlterSequenceOwnerMigration("user_id_seq", "app").SQL(d))
+	assert.Equal(`ALTER VIEW "user_stats" OWNER TO "app"`, NewAlterViewOwnerMigration("user_stats", "app").SQL(d))
+}
+
+func TestAlterEnumTypeMigration(t *testing.T) {
+	m := NewAlterEnumTypeMigration("order_status", "refunded")
+
+	mg, mock := newTestMigrator(t)
+	mock.ExpectQuery(`SHOW server_version_num`).
+		WillReturnRows(sqlmock.NewRows([]string{"server_version_num"}).AddRow("150004"))
+	assert.Equal(t, `ALTER TYPE "order_status" ADD VALUE IF NOT EXISTS 'refunded'`, m.SQL(mg.Dialect))
+
+	// without a known server version the condition has to do
+	assert.Equal(t, `ALTER TYPE "order_status" ADD VALUE 'refunded'`, m.SQL(NewPostgresDialect(nil)))
+
+	sql, args := m.GetCondition().Sql(mg.Dialect)
+	assert.Equal(t, "SELECT 1 FROM pg_enum e JOIN pg_type t ON t.oid = e.enumtypid WHERE t.typname = ? AND pg_type_is_visible(t.oid) AND e.enumlabel = ?", sql)
+	assert.Equal(t, []interface{}{"order_status", "refunded"}, args)
+
+	// ADD VALUE runs in a transaction from Postgres 12 on
+	assert.False(t, m.NonTransactionalFor(mg.Dialect))
+	old := NewPostgresDialect(nil)
+	old.version = 110000
+	assert.True(t, m.NonTransactionalFor(old))
+	assert.True(t, m.NonTransactionalFor(NewPostgresDialect(nil)))
+}
+
+func TestCastMigrations(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewPostgresDialect(nil)
+
+	m := NewCreateCastMigration("money_amount", "numeric", "money_amount_to_numeric", CastAssignment)
+	assert.NoError(m.Validate())
+	assert.Equal(`CREATE CAST (money_amount AS numeric) WITH FUNCTION "money_amount_to_numeric"(money_amount) AS ASSIGNMENT`, m.SQL(d))
+
+	down, err := m.DownSQL(d)
+	assert.NoError(err)
+	assert.Equal(`DROP CAST IF EXISTS (money_amount AS numeric)`, down)
+
+	explicit := NewCreateCastMigration("money_amount", "text", "money_amount_to_text", CastExplicit)
+	assert.Equal(`CREATE CAST (money_amount AS text) WITH FUNCTION "money_amount_to_text"(money_amount)`, explicit.SQL(d))
+
+	qualified := NewCreateCastMigration("money_amount", "numeric", "billing.money_amount_to_numeric", CastImplicit)
+	assert.Equal(`CREATE CAST (money_amount AS numeric) WITH FUNCTION "billing"."money_amount_to_numeric"(money_amount) AS IMPLICIT`, qualified.SQL(d))
+
+	assert.Error(NewCreateCastMigration("money_amount", "text", "money_amount_to_text", "").Validate())
+	assert.Equal(`DROP CAST IF EXISTS (money_amount AS text)`, NewDropCastMigration("money_amount", "text").SQL(d))
+}
+
+func TestAggregateMigrations(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewPostgresDialect(nil)
+
+	m := NewCreateAggregateMigration("weighted_avg", []string{"numeric", "numeric"}, "weighted_avg_step", "numeric[]").
+		FinalFunc("weighted_avg_final").
+		InitCond("{0,0}").
+		OrReplace()
+	assert.Equal(`CREATE OR REPLACE AGGREGATE "weighted_avg" (numeric, numeric) (SFUNC = "weighted_avg_step", STYPE = numeric[], FINALFUNC = "weighted_avg_final", INITCOND = '{0,0}')`, m.SQL(d))
+
+	down, err := m.DownSQL(d)
+	assert.NoError(err)
+	assert.Equal(`DROP AGGREGATE IF EXISTS "weighted_avg" (numeric, numeric)`, down)
+
+	plain := NewCreateAggregateMigration("text_concat", []string{"text"}, "textcat", "text")
+	assert.Equal(`CREATE AGGREGATE "text_concat" (text) (SFUNC = "textcat", STYPE = text)`, plain.SQL(d))
+	assert.Equal(`DROP AGGREGATE IF EXISTS "text_concat" (text)`, NewDropAggregateMigration("text_concat", []string{"text"}).SQL(d))
+
+	counter := NewCreateAggregateMigration("row_counter", nil, "int8inc", "bigint").InitCond("0")
+	assert.Equal(`CREATE AGGREGATE "row_counter" (*) (SFUNC = "int8inc", STYPE = bigint, INITCOND = '0')`, counter.SQL(d))
+	assert.Equal(`DROP AGGREGATE IF EXISTS "row_counter" (*)`, NewDropAggregateMigration("row_counter", nil).SQL(d))
+
+	qualified := NewCreateAggregateMigration("stats.weighted_avg", []string{"numeric", "numeric"}, "stats.weighted_avg_step", "numeric[]").
+		FinalFunc("stats.weighted_avg_final")
+	assert.Equal(`CREATE AGGREGATE "stats"."weighted_avg" (numeric, numeric) (SFUNC = "stats"."weighted_avg_step", STYPE = numeric[], FINALFUNC = "stats"."weighted_avg_final")`, qualified.SQL(d))
+	assert.Equal(`DROP AGGREGATE IF EXISTS "stats"."weighted_avg" (numeric, numeric)`, NewDropAggregateMigration("stats.weighted_avg", []string{"numeric", "numeric"}).SQL(d))
+}
+
+func TestTextSearchConfigMigrations(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewPostgresDialect(nil)
+
+	m := NewCreateTextSearchConfigMigration("german_unaccent", "pg_catalog.german")
+	assert.NoError(m.Validate())
+	assert.Equal(`CREATE TEXT SEARCH CONFIGURATION "german_unaccent" (COPY = pg_catalog.german)`, m.SQL(d))
+
+	down, err := m.DownSQL(d)
+	assert.NoError(err)
+	assert.Equal(`DROP TEXT SEARCH CONFIGURATION IF EXISTS "german_unaccent"`, down)
+
+	parsed := NewCreateTextSearchConfigMigration("plain", "").Parser("default")
+	assert.NoError(parsed.Validate())
+	assert.Equal(`CREATE TEXT SEARCH CONFIGURATION "plain" (PARSER = default)`, parsed.SQL(d))
+	assert.Error(NewCreateTextSearchConfigMigration("plain", "").Validate())
+	assert.Error(NewCreateTextSearchConfigMigration("plain", "simple").Parser("default").Validate())
+
+	tokens := []string{"word", "hword", "hword_part"}
+	dicts := []string{"unaccent", "german_stem"}
+	assert.Equal(`ALTER TEXT SEARCH CONFIGURATION "german_unaccent" ALTER MAPPING FOR word, hword, hword_part WITH unaccent, german_stem`,
+		NewAlterTextSearchConfigMigration("german_unaccent", tokens, dicts).SQL(d))
+	assert.Equal(`ALTER TEXT SEARCH CONFIGURATION "plain" ADD MAPPING FOR word, hword, hword_part WITH unaccent, german_stem`,
+		NewAlterTextSearchConfigMigration("plain", tokens, dicts).AddMapping().SQL(d))
+
+	assert.Equal(`DROP TEXT SEARCH CONFIGURATION IF EXISTS "plain"`, NewDropTextSearchConfigMigration("plain").SQL(d))
+}
+
+func TestReorderColumnsMigration(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewPostgresDialect(nil)
+	table := Table{
+		Name: "team",
+		Columns: []*Column{
+			{Name: "id", Type: DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "created", Type: DB_DateTime, Nullable: false},
+			{Name: "org_id", Type: DB_BigInt, Nullable: false},
+			{Name: "name", Type: DB_NVarchar, Length: 190, Nullable: false, Default: "''"},
+		},
+		Uniques:     []string{"name", "created"},
+		Indices:     []*Index{{Cols: []string{"org_id", "name"}, Type: UniqueIndex}},
+		ForeignKeys: []ForeignKey{{Cols: []string{"org_id"}, RefTable: "org", RefCols: []string{"id"}}},
+	}
+
+	m := NewReorderColumnsMigration(table, []string{"id", "org_id", "name", "created"})
+	assert.NoError(m.Validate())
+
+	statements := strings.Split(m.SQL(d), ";\n")
+	require.Len(t, statements, 9)
+
+	create := statements[0]
+	assert.True(strings.HasPrefix(create, `CREATE TABLE IF NOT EXISTS "team_reorder"`), create)
+	assert.Contains(create, `CONSTRAINT "FK_team_org_id" FOREIGN KEY`)
+	assert.Contains(create, `"name" VARCHAR(190) NOT NULL DEFAULT ''`)
+	positions := []int{
+		strings.Index(create, `"id"`),
+		strings.Index(create, `"org_id"`),
+		strings.Index(create, `"name"`),
+		strings.Index(create, `"created"`),
+	}
+	assert.IsIncreasing(positions)
+
+	order := []string{"id", "org_id", "name", "created"}
+	assert.Equal(d.CopyTableData("team", "team_reorder", order, order), statements[1])
+	assert.Equal(d.DropTable("team"), statements[2])
+	assert.Equal(`ALTER TABLE "team_reorder" RENAME TO "team"`, statements[3])
+	assert.Equal(`ALTER SEQUENCE IF EXISTS "team_reorder_id_seq" RENAME TO "team_id_seq"`, statements[4])
+	assert.Equal(`ALTER TABLE "team" RENAME CONSTRAINT "team_reorder_pkey" TO "team_pkey"`, statements[5])
+	assert.Equal(`ALTER TABLE "team" RENAME CONSTRAINT "team_reorder_name_created_key" TO "team_name_created_key"`, statements[6])
+	assert.Equal(`SELECT setval(pg_get_serial_sequence('"team"', 'id'), COALESCE(MAX("id"), 0) + 1, false) FROM "team"`, statements[7])
+	assert.Equal(`CREATE UNIQUE INDEX "UQE_team_org_id_name" ON "team" ("org_id","name")`, statements[8])
+
+	assert.Error(NewReorderColumnsMigration(table, []string{"id", "org_id", "name"}).Validate())
+	assert.Error(NewReorderColumnsMigration(table, []string{"id", "org_id", "name", "updated"}).Validate())
+	assert.Error(NewReorderColumnsMigration(table, []string{"id", "org_id", "name", "name"}).Validate())
+}
+
+func TestInheritTableMigrations(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewPostgresDialect(nil)
+
+	inherit := NewInheritTableMigration("event_2024", "event")
+	assert.Equal(`ALTER TABLE "event_2024" INHERIT "event"`, inherit.SQL(d))
+	down, err := inherit.DownSQL(d)
+	assert.NoError(err)
+	assert.Equal(`ALTER TABLE "event_2024" NO INHERIT "event"`, down)
+
+	noInherit := NewNoInheritTableMigration("event_2024", "event")
+	assert.Equal(`ALTER TABLE "event_2024" NO INHERIT "event"`, noInherit.SQL(d))
+
+	for _, m := range []Migration{inherit, noInherit} {
+		condition, ok := m.GetCondition().(*AndCondition)
+		if assert.True(ok) {
+			assert.Equal([]MigrationCondition{
+				&IfTableExistsCondition{TableName: "event_2024"},
+				&IfTableExistsCondition{TableName: "event"},
+			}, condition.Conditions)
+		}
+	}
+
+	mysql := &storageEngineDialect{Postgres: NewPostgresDialect(nil)}
+	mysql.BaseDialect.dialect = mysql
+	assert.Equal(mysql.NoOpSql(), mysql.BaseDialect.InheritTableSql("event_2024", "event"))
+	assert.Equal(mysql.NoOpSql(), mysql.BaseDialect.NoInheritTableSql("event_2024", "event"))
+}
+
+func TestPublicationMigrations(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewPostgresDialect(nil)
+
+	create := NewCreatePublicationMigration("events_pub", "event", "event_tag")
+	assert.Equal(`CREATE PUBLICATION "events_pub" FOR TABLE "event", "event_tag"`, create.SQL(d))
+	assert.Equal(`CREATE PUBLICATION "events_pub"`, NewCreatePublicationMigration("events_pub").SQL(d))
+	assert.Equal(`CREATE PUBLICATION "events_pub" FOR ALL TABLES`, NewCreatePublicationMigration("events_pub").AllTables().SQL(d))
+
+	down, err := create.DownSQL(d)
+	assert.NoError(err)
+	assert.Equal(`DROP PUBLICATION IF EXISTS "events_pub"`, down)
+
+	add := NewAddTableToPublicationMigration("events_pub", "audit")
+	assert.Equal(`ALTER PUBLICATION "events_pub" ADD TABLE "audit"`, add.SQL(d))
+	down, err = add.DownSQL(d)
+	assert.NoError(err)
+	assert.Equal(`ALTER PUBLICATION "events_pub" DROP TABLE "audit"`, down)
+
+	drop := NewDropTableFromPublicationMigration("events_pub", "audit")
+	assert.Equal(`ALTER PUBLICATION "events_pub" DROP TABLE "audit"`, drop.SQL(d))
+
+	mysql := NewPostgresDialect(nil)
+	mysql.BaseDialect.driverName = "mysql"
+	for _, m := range []DialectValidatingMigration{create, add, drop} {
+		assert.NoError(m.ValidateDialect(d))
+		assert.Error(m.ValidateDialect(mysql))
+	}
+}
+
+func TestOperatorMigrations(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewPostgresDialect(nil)
+
+	op := NewCreateOperatorMigration("===", "semver", "semver", "semver_eq").Commutator("===").Negator("!==")
+	assert.Equal(`CREATE OPERATOR === (FUNCTION = "semver_eq", LEFTARG = semver, RIGHTARG = semver, COMMUTATOR = ===, NEGATOR = !==)`, op.SQL(d))
+
+	down, err := op.DownSQL(d)
+	assert.NoError(err)
+	assert.Equal(`DROP OPERATOR IF EXISTS === (semver, semver)`, down)
+	assert.Equal(`DROP OPERATOR IF EXISTS ~ (NONE, semver)`, NewDropOperatorMigration("~", "", "semver").SQL(d))
+
+	opClass := NewCreateOperatorClassMigration("semver_ops", "semver", "btree").
+		Default().
+		Operator(1, "<").
+		Operator(3, "===").
+		Function(1, "semver_cmp(semver, semver)")
+	assert.NoError(opClass.Validate())
+	assert.Equal(`CREATE OPERATOR CLASS "semver_ops" DEFAULT FOR TYPE semver USING btree AS OPERATOR 1 <, OPERATOR 3 ===, FUNCTION 1 "semver_cmp"(semver, semver)`, opClass.SQL(d))
+
+	down, err = opClass.DownSQL(d)
+	assert.NoError(err)
+	assert.Equal(`DROP OPERATOR CLASS IF EXISTS "semver_ops" USING btree`, down)
+
+	qualified := NewCreateOperatorMigration("===", "semver", "semver", "semver.semver_eq")
+	assert.Equal(`CREATE OPERATOR === (FUNCTION = "semver"."semver_eq", LEFTARG = semver, RIGHTARG = semver)`, qualified.SQL(d))
+	qualifiedClass := NewCreateOperatorClassMigration("semver_ops", "semver", "btree").Function(1, "semver.semver_cmp(semver, semver)")
+	assert.Equal(`CREATE OPERATOR CLASS "semver_ops" FOR TYPE semver USING btree AS FUNCTION 1 "semver"."semver_cmp"(semver, semver)`, qualifiedClass.SQL(d))
+
+	gist := NewCreateOperatorClassMigration("box_ops", "box2", "gist").Operator(3, "&&").Storage("box")
+	assert.Equal(`CREATE OPERATOR CLASS "box_ops" FOR TYPE box2 USING gist AS OPERATOR 3 &&, STORAGE box`, gist.SQL(d))
+	assert.Error(NewCreateOperatorClassMigration("empty_ops", "box2", "gist").Validate())
+}
+
+func TestSetReplicaIdentityMigration(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewPostgresDialect(nil)
+	table := Table{Name: "order"}
+
+	assert.Equal(`ALTER TABLE "order" REPLICA IDENTITY FULL`, NewSetReplicaIdentityMigration(table, ReplicaIdentityFull).SQL(d))
+	assert.Equal(`ALTER TABLE "order" REPLICA IDENTITY DEFAULT`, NewSetReplicaIdentityMigration(table, ReplicaIdentityDefault).SQL(d))
+	assert.Equal(`ALTER TABLE "order" REPLICA IDENTITY NOTHING`, NewSetReplicaIdentityMigration(table, ReplicaIdentityNothing).SQL(d))
+	assert.Equal(`ALTER TABLE "order" REPLICA IDENTITY USING INDEX "UQE_order_uuid"`,
+		NewSetReplicaIdentityMigration(table, ReplicaIdentityUsingIndex("UQE_order_uuid")).SQL(d))
+}
+
+func TestAddColumnsMigration(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewPostgresDialect(nil)
+	m := NewAddColumnsMigration(Table{Name: "user"}, []*Column{
+		{Name: "city", Type: DB_Text, Nullable: true},
+		{Name: "visits", Type: DB_BigInt, Default: "0"},
+	})
+
+	assert.NoError(m.Validate())
+	assert.Equal(`ALTER TABLE "user" ADD COLUMN "city" TEXT NULL, ADD COLUMN "visits" BIGINT NOT NULL DEFAULT 0`, m.SQL(d))
+	assert.Equal(&IfColumnsNotExistCondition{TableName: "user", ColumnNames: []string{"city", "visits"}}, m.GetCondition())
+
+	down, err := m.DownSQL(d)
+	assert.NoError(err)
+	assert.Equal("ALTER TABLE \"user\" DROP COLUMN \"city\";\nALTER TABLE \"user\" DROP COLUMN \"visits\"", down)
+
+	assert.Error(NewAddColumnsMigration(Table{Name: "user"}, nil).Validate())
+	assert.NoError(NewAddColumnsMigration(Table{Name: "user"}, []*Column{{Name: "zip", Type: DB_Text}}).Validate())
+}
+
+func TestRemoveColumnsMigration(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewPostgresDialect(nil)
+	m := NewRemoveColumnsMigration(Table{Name: "user"}, []string{"city", "zip"})
+
+	assert.NoError(m.Validate())
+	assert.Nil(m.GetCondition())
+	assert.Equal(`ALTER TABLE "user" DROP COLUMN IF EXISTS "city", DROP COLUMN IF EXISTS "zip"`, m.SQL(d))
+
+	assert.Error(NewRemoveColumnsMigration(Table{Name: "user"}, nil).Validate())
+}
+
+func TestAddIndexMigrationUnique(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewPostgresDialect(nil)
+	table := Table{Name: "user"}
+	index := &Index{Cols: []string{"email"}, Type: UniqueIndex}
+
+	assert.Equal(`CREATE UNIQUE INDEX "UQE_user_email" ON "user" ("email")`, NewAddIndexMigration(table, index).SQL(d))
+	assert.Equal(`CREATE UNIQUE INDEX CONCURRENTLY "UQE_user_email" ON "user" ("email")`,
+		NewAddIndexMigration(table, index).Concurrently().SQL(d))
+
+	index.Type = IndexType
+	assert.Equal(`CREATE INDEX "IDX_user_email" ON "user" ("email")`, NewAddIndexMigration(table, index).SQL(d))
+}
+
+func TestAddIndexMigrationUniqueIndependentOfColumns(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewPostgresDialect(nil)
+	table := Table{
+		Name:    "user",
+		Columns: []*Column{{Name: "org_id", Type: DB_BigInt}, {Name: "login", Type: DB_Text}},
+	}
+
+	m := NewAddIndexMigration(table, &Index{Cols: []string{"org_id", "login"}, Type: UniqueIndex})
+	assert.Equal(`CREATE UNIQUE INDEX "UQE_user_org_id_login" ON "user" ("org_id","login")`, m.SQL(d))
+	assert.Equal(&IfIndexNotExistsCondition{TableName: "user", IndexName: "UQE_user_org_id_login"}, m.GetCondition())
+
+	down, err := m.DownSQL(d)
+	assert.NoError(err)
+	assert.Contains(down, `"UQE_user_org_id_login"`)
+}
+
+func TestUnloggedTable(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewPostgresDialect(nil)
+	table := Table{
+		Name:     "import_staging",
+		Columns:  []*Column{{Name: "id", Type: DB_BigInt, IsPrimaryKey: true}},
+		Unlogged: true,
+	}
+
+	assert.Equal(`CREATE UNLOGGED TABLE IF NOT EXISTS "import_staging" (
+"id" BIGINT PRIMARY KEY NOT NULL
+)`, NewAddTableMigration(table).SQL(d))
+
+	assert.Equal(`ALTER TABLE "import_staging" SET LOGGED`, NewSetTableLoggedMigration("import_staging", true).SQL(d))
+	assert.Equal(`ALTER TABLE "import_staging" SET UNLOGGED`, NewSetTableLoggedMigration("import_staging", false).SQL(d))
+}
+
+func TestClusterTableMigration(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewPostgresDialect(nil)
+	m := NewClusterTableMigration(Table{Name: "event"}, "IDX_event_created")
+
+	assert.Equal(`CLUSTER "event" USING "IDX_event_created"`, m.SQL(d))
+	assert.True(m.NonTransactional())
+}
+
+func TestDropTableMigrationRenameBeforeDrop(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewPostgresDialect(nil)
+
+	m := NewDropTableMigration("user")
+	assert.Equal(`DROP TABLE IF EXISTS "user"`, m.SQL(d))
+	assert.Equal(&IfTableExistsCondition{TableName: "user"}, m.GetCondition())
+
+	m.RenameBeforeDrop = true
+	m.renamedAt = time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+	assert.Equal(`ALTER TABLE "user" RENAME TO "_drop_user_20260301123000"`, m.SQL(d))
+	assert.Equal(&IfTableExistsCondition{TableName: "user"}, m.GetCondition())
+
+	// long names are cut, keeping the timestamp
+	assert.Len(droppedTablePrefix(strings.Repeat("x", 60), d)+"20260301123000", d.MaxIdentifierLength())
+}
+
+func TestDestructiveMigrations(t *testing.T) {
+	assert := assert.New(t)
+
+	renamed := NewDropTableMigration("user")
+	renamed.RenameBeforeDrop = true
+
+	assert.True(isDestructive(NewDropTableMigration("user")))
+	assert.True(isDestructive(NewRemoveColumnMigration(Table{Name: "user"}, "email")))
+	assert.True(isDestructive(NewRemoveColumnsMigration(Table{Name: "user"}, []string{"city", "zip"})))
+	assert.True(isDestructive(NewResetDatabaseMigration()))
+
+	assert.False(isDestructive(renamed))
+	assert.False(isDestructive(NewAddTableMigration(Table{Name: "user"})))
+	assert.False(isDestructive(NewAddColumnMigration(Table{Name: "user"}, &Column{Name: "email", Type: DB_Text})))
+	assert.False(isDestructive(NewRawSqlMigration("DELETE FROM session")))
+}
+
+func TestDropConstraintMigrationIfExists(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewPostgresDialect(nil)
+	table := Table{Name: "order"}
+	fk := &ForeignKey{Cols: []string{"user_id"}, RefTable: "user", RefCols: []string{"id"}}
+
+	assert.Equal(`ALTER TABLE "order" DROP CONSTRAINT "order_total_check"`, NewDropConstraintMigration(table, "order_total_check").SQL(d))
+	assert.Nil(NewDropConstraintMigration(table, "order_total_check").GetCondition())
+
+	m := NewDropConstraintMigration(table, "order_total_check").IfExists()
+	assert.Equal(`ALTER TABLE "order" DROP CONSTRAINT IF EXISTS "order_total_check"`, m.SQL(d))
+
+	fkm := NewDropForeignKeyMigration(table, fk).IfExists()
+	assert.Equal(`ALTER TABLE "order" DROP CONSTRAINT IF EXISTS "FK_order_user_id"`, fkm.SQL(d))
+
+	// dialects without IF EXISTS rely on the condition
+	mysql := &storageEngineDialect{Postgres: NewPostgresDialect(nil)}
+	mysql.BaseDialect.dialect = mysql
+	assert.Equal("ALTER TABLE `order` DROP FOREIGN KEY `FK_order_user_id`", mysql.BaseDialect.DropForeignKeySql("order", "FK_order_user_id", true))
+	assert.Equal(&IfConstraintExistsCondition{TableName: "order", ConstraintName: "FK_order_user_id"}, fkm.GetCondition())
+
+	sql, args := fkm.GetCondition().Sql(d)
+	assert.Equal("SELECT 1 FROM information_schema.table_constraints WHERE table_schema = current_schema() AND table_name = ? AND constraint_name = ?", sql)
+	assert.Equal([]interface{}{"order", "FK_order_user_id"}, args)
+}
+
+func TestLockTableMigration(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewPostgresDialect(nil)
+	table := Table{Name: "order"}
+
+	m := NewLockTableMigration(table, LockShareUpdateExclusive)
+	assert.NoError(m.Validate())
+	assert.Equal(`LOCK TABLE "order" IN SHARE UPDATE EXCLUSIVE MODE`, m.SQL(d))
+	assert.Equal(`LOCK TABLE "order" IN ACCESS SHARE MODE`, NewLockTableMigration(table, LockAccessShare).SQL(d))
+
+	assert.Error(NewLockTableMigration(table, "NO KEY UPDATE").Validate())
+}
+
+func TestAddColumnMigrationFill(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewPostgresDialect(nil)
+	col := &Column{Name: "status", Type: DB_NVarchar, Length: 20}
+
+	m := NewAddColumnMigration(Table{Name: "user"}, col)
+	m.Fill("'active'")
+	assert.Equal(`alter table "user" ADD COLUMN "status" VARCHAR(20) NULL;
+UPDATE "user" SET "status" = 'active';
+ALTER TABLE "user" ALTER COLUMN "status" SET NOT NULL`, m.SQL(d))
+}
+
+func TestReversibleMigrationsDownSQL(t *testing.T) {
+	d := NewPostgresDialect(nil)
+	table := Table{Name: "user", Columns: []*Column{{Name: "id", Type: DB_BigInt, IsPrimaryKey: true}}}
+
+	tests := map[string]struct {
+		migration ReversibleMigration
+		down      string
+	}{
+		"add table":   {NewAddTableMigration(table), `DROP TABLE IF EXISTS "user"`},
+		"add column":  {NewAddColumnMigration(table, &Column{Name: "email", Type: DB_Text, Nullable: true}), `ALTER TABLE "user" DROP COLUMN "email"`},
+		"add index":   {NewAddIndexMigration(table, &Index{Cols: []string{"email"}}), `DROP INDEX "IDX_user_email" CASCADE`},
+		"create view": {NewCreateViewMigration("active_user", `SELECT * FROM "user" WHERE active`), `DROP VIEW IF EXISTS "active_user"`},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			down, err := tc.migration.DownSQL(d)
+			require.NoError(t, err)
+			assert.Equal(t, tc.down, down)
+		})
+	}
+
+	_, err := NewCopyTableDataMigration("user_copy", "user", map[string]string{"id": "id"}).DownSQL(d)
+	assert.ErrorIs(t, err, ErrIrreversibleMigration)
+}
+
+func TestRowLevelSecurityMigrations(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewPostgresDialect(nil)
+	table := Table{Name: "document"}
+
+	assert.Equal(`ALTER TABLE "document" ENABLE ROW LEVEL SECURITY`, NewEnableRLSMigration(table).SQL(d))
+	assert.Equal(`ALTER TABLE "document" DISABLE ROW LEVEL SECURITY`, NewDisableRLSMigration(table).SQL(d))
+	assert.Equal(`ALTER TABLE "document" FORCE ROW LEVEL SECURITY`, NewForceRLSMigration(table).SQL(d))
+	assert.Equal(`ALTER TABLE "document" NO FORCE ROW LEVEL SECURITY`, NewNoForceRLSMigration(table).SQL(d))
+}
+
+func TestEventTriggerMigrations(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewPostgresDialect(nil)
+
+	assert.Equal(`CREATE EVENT TRIGGER "log_ddl" ON ddl_command_end EXECUTE FUNCTION "log_ddl_command"()`,
+		NewCreateEventTriggerMigration("log_ddl", EventDDLCommandEnd, "log_ddl_command").SQL(d))
+	assert.Equal(`CREATE EVENT TRIGGER "no_drops" ON ddl_command_start WHEN TAG IN ('DROP TABLE', 'DROP SCHEMA') EXECUTE FUNCTION "abort_drop"()`,
+		NewCreateEventTriggerMigration("no_drops", EventDDLCommandStart, "abort_drop").WhenTag("DROP TABLE", "DROP SCHEMA").SQL(d))
+	assert.Equal(`CREATE EVENT TRIGGER "audit_ddl" ON sql_drop EXECUTE FUNCTION "audit"."log_drop"()`,
+		NewCreateEventTriggerMigration("audit_ddl", EventSQLDrop, "audit.log_drop").SQL(d))
+	assert.Equal(`DROP EVENT TRIGGER IF EXISTS "no_drops"`, NewDropEventTriggerMigration("no_drops").SQL(d))
+}
+
+func TestRawSqlMigrationVersionedSql(t *testing.T) {
+	newDialect := func(t *testing.T, version string) Dialect {
+		mg, mock := newTestMigrator(t)
+		mock.ExpectQuery(`SHOW server_version_num`).
+			WillReturnRows(sqlmock.NewRows([]string{"server_version_num"}).AddRow(version))
+		return mg.Dialect
+	}
+
+	m := NewRawSqlMigration("SELECT 'default'").
+		Postgres("SELECT 'postgres'").
+		Set("postgres>=14", "SELECT 'postgres 14'").
+		Set("postgres>=15.2", "SELECT 'postgres 15.2'")
+
+	assert.Equal(t, "SELECT 'postgres 15.2'", m.SQL(newDialect(t, "150004")))
+	assert.Equal(t, "SELECT 'postgres 14'", m.SQL(newDialect(t, "150001")))
+	assert.Equal(t, "SELECT 'postgres'", m.SQL(newDialect(t, "120010")))
+
+	d := newDialect(t, "160000")
+	assert.Equal(t, "SELECT 'default'", NewRawSqlMigration("SELECT 'default'").Set("postgres>=17", "SELECT 'postgres 17'").SQL(d))
+	// the version is read once
+	assert.Equal(t, "SELECT 'postgres 15.2'", m.SQL(d))
+
+	assert.Panics(t, func() { NewRawSqlMigration("SELECT 1").Set("postgres>=fourteen", "SELECT 2") })
+}
+
+func TestRawSqlMigrationVersionedSqlWithoutVersion(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+	// a failed read is tried again every time
+	for i := 0; i < 3; i++ {
+		mock.ExpectQuery(`SHOW server_version_num`).WillReturnError(errors.New("connection refused"))
+	}
+
+	assert.NoError(t, NewRawSqlMigration("SELECT 'default'").ValidateDialect(mg.Dialect))
+
+	m := NewRawSqlMigration("SELECT 'default'").Set("postgres>=14", "SELECT 'postgres 14'")
+	assert.ErrorContains(t, m.ValidateDialect(mg.Dialect), "connection refused")
+	assert.ErrorContains(t, m.ValidateDialect(mg.Dialect), "connection refused")
+	assert.Equal(t, "SELECT 'default'", m.SQL(mg.Dialect))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -1,7 +1,11 @@
 package migrator
 
 import (
+	"context"
 	"fmt"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 
 	_ "github.com/lib/pq"
@@ -15,6 +19,25 @@ type Migrator struct {
 	Dialect      Dialect
 	migrationIds map[string]struct{}
 	migrations   []Migration
+
+	singleTransaction bool
+	filter            func(m Migration) bool
+	now               func() time.Time
+
+	// SearchPath is the schema search path of the migrations, empty for the default.
+	SearchPath string
+
+	// DebugMode logs a trace of every executed migration.
+	DebugMode bool
+
+	// SchemaSnapshot decides exists conditions from one read of the schema.
+	SchemaSnapshot bool
+	snapshot       *schemaSnapshot
+
+	// StrictRawSql refuses raw SQL without a condition or AllowRepeat.
+	StrictRawSql bool
+	// MinServerVersion is the oldest server Preflight accepts, in the format of Dialect.Version.
+	MinServerVersion int
 }
 
 type MigrationLog struct {
@@ -26,6 +49,44 @@ type MigrationLog struct {
 	Timestamp   time.Time
 }
 
+const resetDatabaseMigrationID = "reset database"
+
+// SkipReason tells why a migration was not executed.
+type SkipReason string
+
+const (
+	SkipAlreadyApplied SkipReason = "already applied"
+	SkipConditionFalse SkipReason = "condition false"
+	SkipFiltered       SkipReason = "filtered"
+	SkipNotDue         SkipReason = "not due"
+)
+
+// ExecutionResult describes what MigrateUp did with a migration.
+type ExecutionResult struct {
+	MigrationID  string
+	RowsAffected int64
+	Duration     time.Duration
+	// SkipReason is set when the migration was not executed.
+	SkipReason SkipReason
+}
+
+// MigrationError is returned for a pending migration refused before any ran.
+type MigrationError struct {
+	MigrationID string
+	Err         error
+}
+
+func (e *MigrationError) Error() string {
+	return fmt.Sprintf("invalid migration %s: %v", e.MigrationID, e.Err)
+}
+
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
+var concurrentlyPattern = regexp.MustCompile(
+	`(?is)\b(?:(?:CREATE\s+(?:UNIQUE\s+)?|DROP\s+)INDEX|REINDEX\s+(?:\([^)]*\)\s*)?(?:INDEX|TABLE|SCHEMA|DATABASE|SYSTEM))\s+CONCURRENTLY\b`)
+
 func NewMigrator(engine *xorm.Engine) *Migrator {
 	mg := &Migrator{}
 	mg.engine = engine
@@ -33,6 +94,7 @@ func NewMigrator(engine *xorm.Engine) *Migrator {
 	mg.migrations = make([]Migration, 0)
 	mg.Dialect = NewDialect(mg.engine)
 	mg.migrationIds = make(map[string]struct{})
+	mg.now = time.Now
 	return mg
 }
 
@@ -51,10 +113,14 @@ func (mg *Migrator) AddMigration(id string, m Migration) {
 }
 
 func (mg *Migrator) GetMigrationLog() (map[string]MigrationLog, error) {
+	return readMigrationLog(mg.engine)
+}
+
+func readMigrationLog(engine *xorm.Engine) (map[string]MigrationLog, error) {
 	logMap := make(map[string]MigrationLog)
 	logItems := make([]MigrationLog, 0)
 
-	exists, err := mg.engine.IsTableExist(new(MigrationLog))
+	exists, err := engine.IsTableExist(new(MigrationLog))
 	if err != nil {
 		return nil, fmt.Errorf("%v: %w", "failed to check table existence", err)
 	}
@@ -63,7 +129,7 @@ func (mg *Migrator) GetMigrationLog() (map[string]MigrationLog, error) {
 		return logMap, nil
 	}
 
-	if err = mg.engine.Find(&logItems); err != nil {
+	if err = engine.Find(&logItems); err != nil {
 		return nil, err
 	}
 
@@ -78,134 +144,768 @@ func (mg *Migrator) GetMigrationLog() (map[string]MigrationLog, error) {
 }
 
 func (mg *Migrator) Start() error {
+	_, err := mg.MigrateUp(context.Background())
+	return err
+}
+
+// Preflight checks that the database is reachable and recent enough.
+func (mg *Migrator) Preflight(ctx context.Context) error {
+	if err := mg.engine.PingContext(ctx); err != nil {
+		return fmt.Errorf("%v: %w", "failed to connect to database", err)
+	}
+
+	if mg.MinServerVersion <= 0 {
+		return nil
+	}
+
+	version, err := mg.Dialect.VersionContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if version == 0 {
+		mg.log.Warn("database server version is unknown, skipping the version check")
+		return nil
+	}
+
+	if version < mg.MinServerVersion {
+		return fmt.Errorf("database server version %d is older than the required version %d", version, mg.MinServerVersion)
+	}
+
+	return nil
+}
+
+// MigrateUp runs all pending migrations and reports what was executed.
+func (mg *Migrator) MigrateUp(ctx context.Context) ([]ExecutionResult, error) {
+	return mg.migrate(ctx, mg.migrations, -1)
+}
+
+// MigrateN runs at most n pending migrations.
+func (mg *Migrator) MigrateN(ctx context.Context, n int) error {
+	if n < 0 {
+		return fmt.Errorf("invalid number of migrations: %d", n)
+	}
+
+	_, err := mg.migrate(ctx, mg.migrations, n)
+	return err
+}
+
+// RunFromStep runs the pending migrations registered after startID.
+func (mg *Migrator) RunFromStep(ctx context.Context, startID string) error {
+	idx := slices.IndexFunc(mg.migrations, func(m Migration) bool { return m.Id() == startID })
+	if idx < 0 {
+		return fmt.Errorf("unknown migration: %s", startID)
+	}
+
+	mg.log.Warn("skipping migrations up to the start step",
+		zap.String("id", startID),
+		zap.Int("skipped", idx+1),
+	)
+
+	_, err := mg.migrate(ctx, mg.migrations[idx+1:], -1)
+	return err
+}
+
+func (mg *Migrator) migrate(ctx context.Context, migrations []Migration, limit int) ([]ExecutionResult, error) {
 	mg.log.Info("starting DB migrations")
+	mg.snapshot = nil
 
 	logMap, err := mg.GetMigrationLog()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	migrationsPerformed := 0
-	migrationsSkipped := 0
+	skipped := make(map[string]SkipReason)
+	pending := make([]Migration, 0)
 	start := time.Now()
-	for _, m := range mg.migrations {
-		m := m
+	for _, m := range migrations {
 		_, exists := logMap[m.Id()]
 		if exists {
 			mg.log.Debug("skipping migration: Already executed",
 				zap.String("id", m.Id()),
 			)
-			migrationsSkipped++
+			skipped[m.Id()] = SkipAlreadyApplied
 			continue
 		}
 
-		sql := m.SQL(mg.Dialect)
+		if mg.filter != nil && !mg.filter(m) {
+			mg.log.Debug("skipping migration: Filtered out",
+				zap.String("id", m.Id()),
+			)
+			skipped[m.Id()] = SkipFiltered
+			continue
+		}
+
+		pending = append(pending, m)
+	}
 
-		record := MigrationLog{
-			MigrationID: m.Id(),
-			SQL:         sql,
-			Timestamp:   time.Now(),
+	if limit >= 0 && len(pending) > limit {
+		pending = pending[:limit]
+	}
+
+	for _, m := range pending {
+		if err := mg.validate(m); err != nil {
+			return inRegistrationOrder(migrations, skipped, nil), &MigrationError{MigrationID: m.Id(), Err: err}
 		}
+	}
 
-		err := mg.inTransaction(func(sess *xorm.Session) error {
-			err := mg.exec(m, sess)
-			if err != nil {
-				mg.log.Error("executing migration condition failed",
-					zap.String("sql", sql),
-					zap.Error(err),
-				)
+	var executed []ExecutionResult
+	if mg.singleTransaction {
+		executed, err = mg.runInSingleTransaction(ctx, pending)
+	} else {
+		executed, err = mg.runAll(pending, func(m Migration) (int64, SkipReason, error) {
+			return mg.run(ctx, m)
+		})
+	}
+	results := inRegistrationOrder(migrations, skipped, executed)
+	if err != nil {
+		return results, fmt.Errorf("%v: %w", "migration failed", err)
+	}
+
+	migrationsPerformed := 0
+	for _, result := range results {
+		if result.SkipReason == "" {
+			migrationsPerformed++
+		}
+	}
+
+	mg.log.Info("migrations completed",
+		zap.Int("performed", migrationsPerformed),
+		zap.Int("skipped", len(results)-migrationsPerformed),
+		zap.Duration("duration", time.Since(start)),
+	)
+
+	return results, mg.engine.Sync2()
+}
+
+func inRegistrationOrder(migrations []Migration, skipped map[string]SkipReason, executed []ExecutionResult) []ExecutionResult {
+	executedByID := make(map[string]ExecutionResult, len(executed))
+	for _, result := range executed {
+		executedByID[result.MigrationID] = result
+	}
+
+	results := make([]ExecutionResult, 0, len(skipped)+len(executed))
+	for _, m := range migrations {
+		if reason, ok := skipped[m.Id()]; ok {
+			results = append(results, ExecutionResult{MigrationID: m.Id(), SkipReason: reason})
+		} else if result, ok := executedByID[m.Id()]; ok {
+			results = append(results, result)
+		}
+	}
+
+	return results
+}
+
+func (mg *Migrator) validate(m Migration) error {
+	if vm, ok := m.(ValidatingMigration); ok {
+		if err := vm.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if dm, ok := m.(DialectValidatingMigration); ok {
+		if err := dm.ValidateDialect(mg.Dialect); err != nil {
+			return err
+		}
+	}
+
+	if lm, ok := m.(*LockTableMigration); ok && !mg.singleTransaction && lm.SQL(mg.Dialect) != mg.Dialect.NoOpSql() {
+		return fmt.Errorf("a table lock ends with the transaction of its migration, it needs SingleTransaction or LockTable on the migration it protects")
+	}
+
+	if hm, ok := m.(hookedMigration); ok && hm.locksTables() && mg.nonTransactional(m) {
+		return fmt.Errorf("a table lock needs a transaction, the migration is non-transactional")
+	}
+
+	if _, ok := m.(CodeMigration); ok {
+		return nil
+	}
+
+	if raw, ok := m.(*RawSqlMigration); ok && raw.Condition == nil && !raw.allowRepeat &&
+		raw.SQL(mg.Dialect) != mg.Dialect.NoOpSql() {
+		if mg.StrictRawSql {
+			return fmt.Errorf("raw SQL without a condition has to be marked with AllowRepeat")
+		}
+
+		mg.log.Warn("raw SQL migration has no condition and runs again if it is missing from the migration log",
+			zap.String("id", m.Id()),
+		)
+	}
+
+	if mg.nonTransactional(m) {
+		return nil
+	}
+
+	if concurrentlyPattern.MatchString(m.SQL(mg.Dialect)) {
+		return fmt.Errorf("CONCURRENTLY cannot run inside a transaction, the migration has to be non-transactional")
+	}
+
+	return nil
+}
+
+// SetFilter restricts MigrateUp to the migrations filter accepts.
+func (mg *Migrator) SetFilter(filter func(m Migration) bool) {
+	mg.filter = filter
+}
+
+// SingleTransaction makes MigrateUp run all pending migrations in one transaction.
+func (mg *Migrator) SingleTransaction(enabled bool) {
+	mg.singleTransaction = enabled
+}
+
+func (mg *Migrator) runAll(migrations []Migration, execute func(m Migration) (int64, SkipReason, error)) ([]ExecutionResult, error) {
+	results := make([]ExecutionResult, 0, len(migrations))
+	for _, m := range migrations {
+		migrationStart := time.Now()
+		rowsAffected, skipReason, err := execute(m)
+		if err != nil {
+			return results, err
+		}
+
+		results = append(results, ExecutionResult{
+			MigrationID:  m.Id(),
+			RowsAffected: rowsAffected,
+			Duration:     time.Since(migrationStart),
+			SkipReason:   skipReason,
+		})
+	}
+
+	return results, nil
+}
+
+func (mg *Migrator) runInSingleTransaction(ctx context.Context, migrations []Migration) ([]ExecutionResult, error) {
+	for _, m := range migrations {
+		if mg.nonTransactional(m) {
+			return nil, fmt.Errorf("migration %q cannot run in a single transaction: it is non-transactional", m.Id())
+		}
+
+		if rm, ok := m.(ResumableMigration); ok && rm.Resumable() {
+			return nil, fmt.Errorf("migration %q cannot run in a single transaction: it is resumable", m.Id())
+		}
+	}
+
+	var results []ExecutionResult
+	err := mg.inTransaction(ctx, func(sess *xorm.Session) error {
+		var err error
+		results, err = mg.runAll(migrations, func(m Migration) (int64, SkipReason, error) {
+			return mg.runInSession(ctx, m, sess)
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// ResetDatabase removes all data from the database, see ResetDatabaseMigration.
+func (mg *Migrator) ResetDatabase(ctx context.Context) error {
+	m := NewResetDatabaseMigration()
+	m.SetId(resetDatabaseMigrationID)
+
+	mg.log.Warn("resetting database")
+
+	_, _, err := mg.run(ctx, m)
+	return err
+}
+
+// Reset wipes the database and runs all migrations again.
+func (mg *Migrator) Reset(ctx context.Context) error {
+	mg.log.Warn("wiping database and running all migrations again")
+
+	if err := mg.Dialect.CleanDB(); err != nil {
+		return fmt.Errorf("%v: %w", "failed to clean database", err)
+	}
+
+	_, err := mg.MigrateUp(ctx)
+	return err
+}
+
+// ApplyOne runs the registered migration with the given id, even if already applied.
+func (mg *Migrator) ApplyOne(id string) error {
+	idx := slices.IndexFunc(mg.migrations, func(m Migration) bool { return m.Id() == id })
+	if idx < 0 {
+		return fmt.Errorf("unknown migration: %s", id)
+	}
+
+	m := mg.migrations[idx]
+	if err := mg.validate(m); err != nil {
+		return &MigrationError{MigrationID: id, Err: err}
+	}
+
+	mg.snapshot = nil
+	if _, _, err := mg.run(context.Background(), m); err != nil {
+		return fmt.Errorf("%v: %w", "migration failed", err)
+	}
+
+	return nil
+}
+
+func (mg *Migrator) nonTransactional(m Migration) bool {
+	if nt, ok := m.(NonTransactionalMigration); ok && nt.NonTransactional() {
+		return true
+	}
+
+	if nt, ok := m.(DialectNonTransactionalMigration); ok && nt.NonTransactionalFor(mg.Dialect) {
+		return true
+	}
+
+	return false
+}
+
+func (mg *Migrator) run(ctx context.Context, m Migration) (int64, SkipReason, error) {
+	if rm, ok := m.(ResumableMigration); ok && rm.Resumable() {
+		return mg.runResumable(ctx, rm)
+	}
+
+	runner := mg.inTransaction
+	if mg.nonTransactional(m) {
+		runner = mg.withoutTransaction
+	}
+
+	var rowsAffected int64
+	var skipReason SkipReason
+	err := runner(ctx, func(sess *xorm.Session) error {
+		var err error
+		rowsAffected, skipReason, err = mg.runInSession(ctx, m, sess)
+		return err
+	})
+
+	return rowsAffected, skipReason, err
+}
+
+func (mg *Migrator) runInSession(ctx context.Context, m Migration, sess *xorm.Session) (int64, SkipReason, error) {
+	if tm, ok := m.(timedMigration); ok {
+		tm.setRunTime(mg.now())
+	}
+
+	sql := loggableSql(m, mg.Dialect)
+
+	record := MigrationLog{
+		MigrationID: m.Id(),
+		SQL:         sql,
+		Timestamp:   time.Now(),
+	}
+
+	var rowsAffected int64
+	var skipReason SkipReason
+	err := mg.withSearchPath(sess, m, func() error {
+		var err error
+		rowsAffected, skipReason, err = mg.exec(ctx, m, sess)
+		return err
+	})
+	if err != nil {
+		mg.log.Error("executing migration condition failed",
+			zap.String("sql", sql),
+			zap.Error(err),
+		)
+
+		record.Error = err.Error()
+		if _, err := sess.Insert(&record); err != nil {
+			return 0, "", err
+		}
+		return 0, "", err
+	}
+
+	if skipReason == SkipNotDue {
+		return 0, skipReason, nil
+	}
+
+	record.Success = true
+	_, err = sess.Insert(&record)
+	return rowsAffected, skipReason, err
+}
+
+func (mg *Migrator) runResumable(ctx context.Context, m ResumableMigration) (int64, SkipReason, error) {
+	mg.log.Info("executing resumable migration",
+		zap.String("id", m.Id()),
+	)
+
+	if err := mg.ensureCheckpointTable(ctx); err != nil {
+		return 0, "", err
+	}
+
+	sess := mg.engine.NewSession().Context(ctx)
+	defer sess.Close()
+
+	var fulfilled bool
+	err := mg.withSearchPath(sess, m, func() error {
+		var err error
+		fulfilled, err = mg.checkCondition(m, sess)
+		return err
+	})
+	if err != nil {
+		return 0, "", err
+	}
+
+	checkpoint, err := mg.getCheckpoint(sess, m.Id())
+	if err != nil {
+		return 0, "", err
+	}
 
-				record.Error = err.Error()
-				if _, err := sess.Insert(&record); err != nil {
+	if checkpoint != "" {
+		mg.log.Info("resuming migration from checkpoint",
+			zap.String("id", m.Id()),
+			zap.String("checkpoint", checkpoint),
+		)
+	}
+
+	var rowsAffected int64
+	done := !fulfilled
+	for !done {
+		err := mg.inTransaction(ctx, func(sess *xorm.Session) error {
+			var next string
+			var rows int64
+			err := mg.withSearchPath(sess, m, func() error {
+				return mg.withHooks(m, sess, func() error {
+					var err error
+					next, rows, err = m.ExecStep(sess, mg, checkpoint)
 					return err
-				}
+				})
+			})
+			if err != nil {
 				return err
 			}
-			record.Success = true
-			_, err = sess.Insert(&record)
-			if err == nil {
-				migrationsPerformed++
+
+			rowsAffected += rows
+			if next == "" {
+				done = true
+				return nil
 			}
-			return err
+
+			checkpoint = next
+			return mg.saveCheckpoint(sess, m.Id(), checkpoint)
 		})
 		if err != nil {
-			return fmt.Errorf("%v: %w", "migration failed", err)
+			mg.log.Error("executing resumable migration failed",
+				zap.String("id", m.Id()),
+				zap.String("checkpoint", checkpoint),
+				zap.Error(err),
+			)
+			return rowsAffected, "", err
 		}
 	}
 
-	mg.log.Info("migrations completed",
-		zap.Int("performed", migrationsPerformed),
-		zap.Int("skipped", migrationsSkipped),
-		zap.Duration("duration", time.Since(start)),
-	)
+	record := MigrationLog{
+		MigrationID: m.Id(),
+		SQL:         loggableSql(m, mg.Dialect),
+		Success:     true,
+		Timestamp:   time.Now(),
+	}
 
-	return mg.engine.Sync2()
+	err = mg.inTransaction(ctx, func(sess *xorm.Session) error {
+		if err := mg.clearCheckpoint(sess, m.Id()); err != nil {
+			return err
+		}
+
+		_, err := sess.Insert(&record)
+		return err
+	})
+
+	if !fulfilled {
+		return rowsAffected, SkipConditionFalse, err
+	}
+
+	return rowsAffected, "", err
 }
 
-func (mg *Migrator) exec(m Migration, sess *xorm.Session) error {
+func (mg *Migrator) exec(ctx context.Context, m Migration, sess *xorm.Session) (int64, SkipReason, error) {
 
 	mg.log.Info("executing migration",
 		zap.String("id", m.Id()),
 	)
 
-	condition := m.GetCondition()
-	if condition != nil {
-		sql, args := condition.Sql(mg.Dialect)
+	conditionStart := time.Now()
+	fulfilled, err := mg.checkCondition(m, sess)
+	conditionDuration := time.Since(conditionStart)
+	if err != nil {
+		return 0, "", err
+	}
+
+	if !fulfilled {
+		mg.log.Warn("skipping migration: Already executed, but not recorded in migration log",
+			zap.String("id", m.Id()),
+		)
+		return 0, SkipConditionFalse, nil
+	}
+
+	if sm, ok := m.(scheduledMigration); ok {
+		due, err := sm.due(sess, mg)
+		if err != nil {
+			return 0, "", err
+		}
 
-		if sql != "" {
-			mg.log.Debug("executing migration condition sql",
+		if !due {
+			mg.log.Info("skipping migration: Not due yet",
 				zap.String("id", m.Id()),
-				zap.String("sql", sql),
-				// zap.ObjectValues("args", args),
 			)
+			return 0, SkipNotDue, nil
+		}
+	}
 
-			results, err := sess.SQL(sql, args...).Query()
-			if err != nil {
-				mg.log.Error("executing migration condition failed",
-					zap.String("id", m.Id()),
-					zap.String("error", err.Error()),
-				)
-				return err
-			}
+	var rowsAffected int64
+	execStart := time.Now()
+	err = mg.withHooks(m, sess, func() error {
+		var err error
+		rowsAffected, err = mg.execMigration(ctx, m, sess)
+		return err
+	})
+	mg.snapshot = nil
+	if err != nil {
+		mg.log.Error("Executing migration condition failed",
+			zap.String("id", m.Id()),
+			zap.Error(err),
+		)
+		return 0, "", err
+	}
 
-			if !condition.IsFulfilled(results) {
-				mg.log.Warn("skipping migration: Already executed, but not recorded in migration log",
-					zap.String("id", m.Id()),
-				)
-				return nil
+	if mg.DebugMode {
+		mg.log.Debug("migration trace",
+			zap.String("id", m.Id()),
+			zap.String("sql", loggableSql(m, mg.Dialect)),
+			zap.Duration("condition_duration", conditionDuration),
+			zap.Duration("exec_duration", time.Since(execStart)),
+			zap.Int64("rows_affected", rowsAffected),
+		)
+	}
+
+	return rowsAffected, "", nil
+}
+
+func (mg *Migrator) withSearchPath(sess *xorm.Session, m Migration, fn func() error) (err error) {
+	schemas := mg.searchPathFor(m)
+	if len(schemas) == 0 {
+		return fn()
+	}
+
+	sql := mg.Dialect.SetSearchPathSql(schemas, false)
+	if sql == mg.Dialect.NoOpSql() {
+		return fn()
+	}
+
+	if _, err := sess.Exec(sql); err != nil {
+		return err
+	}
+
+	defer func() {
+		if _, resetErr := sess.Exec(mg.Dialect.SetSearchPathSql(nil, false)); err == nil {
+			err = resetErr
+		}
+	}()
+
+	return fn()
+}
+
+func (mg *Migrator) searchPathFor(m Migration) []string {
+	if _, ok := m.(*SetSearchPathMigration); ok {
+		return nil
+	}
+
+	var schemas []string
+	if mg.SearchPath != "" {
+		schemas = []string{mg.SearchPath}
+	}
+
+	scoped := schemas
+	for _, registered := range mg.migrations {
+		if registered == m {
+			return scoped
+		}
+
+		if sp, ok := registered.(*SetSearchPathMigration); ok {
+			scoped = sp.schemas
+			if len(scoped) == 0 {
+				scoped = schemas
 			}
 		}
 	}
 
+	return schemas
+}
+
+func (mg *Migrator) withHooks(m Migration, sess *xorm.Session, fn func() error) (err error) {
+	hooks, ok := m.(hookedMigration)
+	if !ok {
+		return fn()
+	}
+
+	defer func() {
+		if err != nil && sess.Tx() != nil && mg.Dialect.TransactionalSettings() {
+			return
+		}
+
+		if restoreErr := mg.execHooks(m, sess, hooks.restoreSql(mg.Dialect)); err == nil {
+			err = restoreErr
+		}
+	}()
+
+	if err := mg.execHooks(m, sess, hooks.beforeSql(mg.Dialect)); err != nil {
+		return err
+	}
+
+	if err := fn(); err != nil {
+		return err
+	}
+
+	return mg.execHooks(m, sess, hooks.afterSql(mg.Dialect))
+}
+
+func (mg *Migrator) execHooks(m Migration, sess *xorm.Session, statements []string) error {
+	for _, sql := range statements {
+		mg.log.Debug("Executing migration hook",
+			zap.String("id", m.Id()),
+			zap.String("sql", sql))
+
+		if _, err := sess.Exec(sql); err != nil {
+			mg.log.Error("Executing migration hook failed",
+				zap.String("id", m.Id()),
+				zap.Error(err),
+			)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (mg *Migrator) execMigration(ctx context.Context, m Migration, sess *xorm.Session) (int64, error) {
 	var err error
-	if codeMigration, ok := m.(CodeMigration); ok {
+	var rowsAffected int64
+	if funcMigration, ok := m.(*FuncMigration); ok {
+		mg.log.Debug("Executing func migration",
+			zap.String("id", m.Id()))
+
+		err = funcMigration.Run(ctx, sess)
+	} else if codeMigration, ok := m.(CodeMigration); ok {
 		mg.log.Debug("Executing code migration",
 			zap.String("id", m.Id()))
 
 		err = codeMigration.Exec(sess, mg)
 	} else {
 		sql := m.SQL(mg.Dialect)
+		if sql == mg.Dialect.NoOpSql() {
+			mg.log.Debug("skipping migration: No SQL for dialect",
+				zap.String("id", m.Id()),
+				zap.String("dialect", mg.Dialect.DriverName()))
+			return 0, nil
+		}
+
 		mg.log.Debug("Executing sql migration",
 			zap.String("id", m.Id()),
-			zap.String("sql", sql))
-		_, err = sess.Exec(sql)
+			zap.String("sql", loggableSql(m, mg.Dialect)))
+
+		if cm, ok := m.(checkedMigration); ok {
+			if err := cm.check(sess, mg.Dialect); err != nil {
+				return 0, err
+			}
+		}
+
+		res, execErr := sess.Exec(sql)
+		if execErr == nil {
+			if n, rowsErr := res.RowsAffected(); rowsErr == nil {
+				rowsAffected = n
+			}
+
+			if vm, ok := m.(verifiedMigration); ok {
+				execErr = vm.verify(sess, mg.Dialect, res)
+			}
+		}
+		err = execErr
 	}
 
+	return rowsAffected, err
+}
+
+func loggableSql(m Migration, d Dialect) string {
+	if sm, ok := m.(SensitiveMigration); ok {
+		return sm.RedactedSQL(d)
+	}
+
+	return m.SQL(d)
+}
+
+func (mg *Migrator) checkCondition(m Migration, sess *xorm.Session) (bool, error) {
+	condition := m.GetCondition()
+	if condition == nil {
+		return true, nil
+	}
+
+	if mg.SchemaSnapshot {
+		searchPath := strings.Join(mg.searchPathFor(m), ",")
+		if mg.snapshot == nil || mg.snapshot.searchPath != searchPath {
+			snapshot, err := loadSchemaSnapshot(mg.Dialect, sess)
+			if err != nil {
+				return false, fmt.Errorf("%v: %w", "failed to read schema snapshot", err)
+			}
+			snapshot.searchPath = searchPath
+			mg.snapshot = snapshot
+		}
+
+		if fulfilled, ok := mg.snapshot.evaluate(condition, mg.Dialect); ok {
+			return fulfilled, nil
+		}
+	}
+
+	var sql string
+	var args []interface{}
+	if _, ok := condition.(EvaluatingCondition); !ok {
+		sql, args = condition.Sql(mg.Dialect)
+		if sql == "" {
+			return true, nil
+		}
+
+		mg.log.Debug("executing migration condition sql",
+			zap.String("id", m.Id()),
+			zap.String("sql", sql),
+		)
+	}
+
+	fulfilled, err := evaluateCondition(condition, mg.Dialect, sess)
 	if err != nil {
-		mg.log.Error("Executing migration condition failed",
+		mg.log.Error("executing migration condition failed",
 			zap.String("id", m.Id()),
-			zap.Error(err),
+			zap.String("error", err.Error()),
 		)
-		return err
+		return false, err
 	}
 
-	return nil
+	mg.log.Debug("migration condition checked",
+		zap.String("id", m.Id()),
+		zap.String("sql", sql),
+		zap.Any("args", args),
+		zap.Bool("fulfilled", fulfilled),
+	)
+
+	return fulfilled, nil
+}
+
+func evaluateCondition(condition MigrationCondition, dialect Dialect, sess xorm.Interface) (bool, error) {
+	if condition == nil {
+		return true, nil
+	}
+
+	if ec, ok := condition.(EvaluatingCondition); ok {
+		return ec.Evaluate(dialect, sess)
+	}
+
+	sql, args := condition.Sql(dialect)
+	if sql == "" {
+		return true, nil
+	}
+
+	results, err := sess.SQL(sql, args...).Query()
+	if err != nil {
+		return false, err
+	}
+
+	return condition.IsFulfilled(results), nil
 }
 
 type dbTransactionFunc func(sess *xorm.Session) error
 
-func (mg *Migrator) inTransaction(callback dbTransactionFunc) error {
-	sess := mg.engine.NewSession()
+func (mg *Migrator) inTransaction(ctx context.Context, callback dbTransactionFunc) error {
+	sess := mg.engine.NewSession().Context(ctx)
 	defer sess.Close()
 
 	if err := sess.Begin(); err != nil {
@@ -226,3 +926,10 @@ func (mg *Migrator) inTransaction(callback dbTransactionFunc) error {
 
 	return nil
 }
+
+func (mg *Migrator) withoutTransaction(ctx context.Context, callback dbTransactionFunc) error {
+	sess := mg.engine.NewSession().Context(ctx)
+	defer sess.Close()
+
+	return callback(sess)
+}
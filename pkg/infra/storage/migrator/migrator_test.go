@@ -0,0 +1,1785 @@
+package migrator
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"xorm.io/xorm"
+	"xorm.io/xorm/core"
+)
+
+func newTestMigrator(t *testing.T) (*Migrator, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	engine, err := xorm.NewEngineWithDB(POSTGRES, "postgres://localhost:5432/test?sslmode=disable", core.FromDB(db))
+	require.NoError(t, err)
+
+	return NewMigrator(engine), mock
+}
+
+func expectMigrationLog(mock sqlmock.Sqlmock, appliedIDs ...string) {
+	mock.ExpectQuery(`SELECT tablename FROM pg_tables`).
+		WillReturnRows(sqlmock.NewRows([]string{"tablename"}).AddRow("migration_log"))
+
+	rows := sqlmock.NewRows([]string{"id", "migration_id", "sql", "success", "error", "timestamp"})
+	for i, id := range appliedIDs {
+		rows.AddRow(i+1, id, "", true, "", time.Now())
+	}
+	mock.ExpectQuery(`FROM "migration_log"`).WillReturnRows(rows)
+}
+
+func expectLogRecord(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`INSERT INTO "migration_log"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+}
+
+func expectSync(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT tablename FROM pg_tables`).
+		WillReturnRows(sqlmock.NewRows([]string{"tablename"}))
+}
+
+func TestMigrateUpResumesChunkedCopy(t *testing.T) {
+	const id = "copy user"
+
+	keyRange := func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery(`SELECT MIN\("id"\) AS min_key, MAX\("id"\) AS max_key FROM "user"`).
+			WillReturnRows(sqlmock.NewRows([]string{"min_key", "max_key"}).AddRow(1, 250))
+	}
+	copyChunk := `INSERT INTO "user_copy" .* FROM "user" WHERE "id" > \$1 AND "id" <= \$2`
+
+	newMigrator := func(t *testing.T) (*Migrator, sqlmock.Sqlmock) {
+		mg, mock := newTestMigrator(t)
+		mg.AddMigration(id, NewCopyTableDataMigration("user_copy", "user", map[string]string{"id": "id"}).InChunks("id", 100))
+		return mg, mock
+	}
+
+	t.Run("interrupted run stores the last copied key", func(t *testing.T) {
+		mg, mock := newMigrator(t)
+
+		expectMigrationLog(mock)
+		mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "migration_checkpoint"`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(`SELECT "checkpoint" FROM "migration_checkpoint"`).WithArgs(id).
+			WillReturnRows(sqlmock.NewRows([]string{"checkpoint"}))
+
+		mock.ExpectBegin()
+		keyRange(mock)
+		mock.ExpectExec(copyChunk).WithArgs(int64(0), int64(100)).WillReturnResult(sqlmock.NewResult(0, 100))
+		mock.ExpectExec(`DELETE FROM "migration_checkpoint"`).WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`INSERT INTO "migration_checkpoint"`).WithArgs(id, "100", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		mock.ExpectBegin()
+		keyRange(mock)
+		mock.ExpectExec(copyChunk).WithArgs(int64(100), int64(200)).WillReturnError(errors.New("connection reset"))
+		mock.ExpectRollback()
+
+		_, err := mg.MigrateUp(context.Background())
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("next run resumes after the checkpoint", func(t *testing.T) {
+		mg, mock := newMigrator(t)
+
+		expectMigrationLog(mock)
+		mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "migration_checkpoint"`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(`SELECT "checkpoint" FROM "migration_checkpoint"`).WithArgs(id).
+			WillReturnRows(sqlmock.NewRows([]string{"checkpoint"}).AddRow("100"))
+
+		mock.ExpectBegin()
+		keyRange(mock)
+		mock.ExpectExec(copyChunk).WithArgs(int64(100), int64(200)).WillReturnResult(sqlmock.NewResult(0, 100))
+		mock.ExpectExec(`DELETE FROM "migration_checkpoint"`).WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`INSERT INTO "migration_checkpoint"`).WithArgs(id, "200", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		mock.ExpectBegin()
+		keyRange(mock)
+		mock.ExpectExec(copyChunk).WithArgs(int64(200), int64(300)).WillReturnResult(sqlmock.NewResult(0, 50))
+		mock.ExpectExec(`DELETE FROM "migration_checkpoint"`).WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`INSERT INTO "migration_checkpoint"`).WithArgs(id, "300", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		mock.ExpectBegin()
+		keyRange(mock)
+		mock.ExpectCommit()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`DELETE FROM "migration_checkpoint"`).WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 1))
+		expectLogRecord(mock)
+		mock.ExpectCommit()
+		expectSync(mock)
+
+		results, err := mg.MigrateUp(context.Background())
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, int64(150), results[0].RowsAffected)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestAlterColumnTypeMigrationRecreatesViews(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+
+	table := Table{Name: "order"}
+	mg.AddMigration("change order amount type", NewAlterColumnTypeMigration(table, []*Column{
+		{Name: "amount", Type: DB_BigInt},
+	}))
+
+	expectMigrationLog(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`WITH RECURSIVE dependent`).WithArgs(`"order"`).
+		WillReturnRows(sqlmock.NewRows([]string{"schema", "name", "kind", "definition"}).
+			AddRow("public", "order_total", "v", ` SELECT sum(amount) AS total FROM "order";`).
+			AddRow("report", "order_summary", "m", ` SELECT total FROM order_total;`))
+	mock.ExpectExec(`DROP MATERIALIZED VIEW "report"."order_summary"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DROP VIEW "public"."order_total"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ALTER TABLE "order" ALTER "amount" TYPE BIGINT`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE VIEW "public"."order_total" AS SELECT sum\(amount\) AS total FROM "order"$`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE MATERIALIZED VIEW "report"."order_summary" AS SELECT total FROM order_total$`).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectLogRecord(mock)
+	mock.ExpectCommit()
+	expectSync(mock)
+
+	_, err := mg.MigrateUp(context.Background())
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReorderColumnsMigrationRecreatesDependents(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+
+	table := Table{
+		Name: "user",
+		Columns: []*Column{
+			{Name: "id", Type: DB_BigInt, IsPrimaryKey: true},
+			{Name: "login", Type: DB_Text},
+		},
+	}
+	mg.AddMigration("reorder user", NewReorderColumnsMigration(table, []string{"login", "id"}))
+
+	expectMigrationLog(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`WITH RECURSIVE dependent`).WithArgs(`"user"`).
+		WillReturnRows(sqlmock.NewRows([]string{"schema", "name", "kind", "definition"}).
+			AddRow("public", "user_login", "v", ` SELECT login FROM "user";`))
+	mock.ExpectQuery(`FROM pg_constraint`).WithArgs(`"user"`).
+		WillReturnRows(sqlmock.NewRows([]string{"schema", "table_name", "name", "definition"}).
+			AddRow("public", "order", "FK_order_user_id", `FOREIGN KEY (user_id) REFERENCES "user"(id)`))
+	mock.ExpectExec(`DROP VIEW "public"."user_login"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ALTER TABLE "public"."order" DROP CONSTRAINT "FK_order_user_id"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "user_reorder"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ALTER TABLE "public"."order" ADD CONSTRAINT "FK_order_user_id" FOREIGN KEY \(user_id\) REFERENCES "user"\(id\)`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE VIEW "public"."user_login" AS SELECT login FROM "user"$`).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectLogRecord(mock)
+	mock.ExpectCommit()
+	expectSync(mock)
+
+	_, err := mg.MigrateUp(context.Background())
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDryRunWithConditions(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+
+	mg.AddMigration("create user table", NewAddTableMigration(Table{
+		Name:    "user",
+		Columns: []*Column{{Name: "id", Type: DB_BigInt, IsPrimaryKey: true}},
+	}))
+	mg.AddMigration("create user stats", NewCreateStatisticsMigration("user_stats", Table{Name: "user"}, []string{"id", "login"}))
+	mg.AddMigration("create order stats", NewCreateStatisticsMigration("order_stats", Table{Name: "order"}, []string{"id", "user_id"}))
+
+	expectMigrationLog(mock, "create user table")
+	mock.ExpectQuery(`FROM pg_statistic_ext`).WithArgs("user_stats").
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+	mock.ExpectQuery(`FROM pg_statistic_ext`).WithArgs("order_stats").
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}))
+
+	results, err := mg.DryRunWithConditions(context.Background(), mg.Dialect, mg.engine)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	assert.False(t, results[0].WouldRun)
+	assert.Equal(t, SkipAlreadyApplied, results[0].Reason)
+	assert.False(t, results[1].WouldRun)
+	assert.Equal(t, SkipConditionFalse, results[1].Reason)
+	assert.True(t, results[2].WouldRun)
+	assert.Equal(t, `CREATE STATISTICS "order_stats" ON "id", "user_id" FROM "order"`, results[2].SQL)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPlanAll(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+
+	mg.AddMigration("create user table", NewAddTableMigration(Table{
+		Name:    "user",
+		Columns: []*Column{{Name: "id", Type: DB_BigInt, IsPrimaryKey: true}},
+	}))
+	mg.AddMigration("add user email", NewAddColumnMigration(Table{Name: "user"}, &Column{Name: "email", Type: DB_Text, Nullable: true}))
+	mg.AddMigration("backfill", NewRawSqlMigration("UPDATE \"user\" SET email = ''"))
+	mg.AddMigration("drop user login", NewRemoveColumnMigration(Table{Name: "user"}, "login"))
+
+	planned := mg.PlanAll(mg.Dialect)
+	require.Len(t, planned, 4)
+
+	assert.Equal(t, "create user table", planned[0].MigrationID)
+	assert.Equal(t, "SELECT 1 FROM pg_tables WHERE schemaname = current_schema() AND tablename = ?", planned[0].ConditionSQL)
+	assert.Equal(t, []interface{}{"user"}, planned[0].ConditionArgs)
+	assert.True(t, strings.HasPrefix(planned[0].SQL, `CREATE TABLE IF NOT EXISTS "user"`))
+
+	assert.Equal(t, []interface{}{"user", "email"}, planned[1].ConditionArgs)
+	assert.Equal(t, `alter table "user" ADD COLUMN "email" TEXT NULL `, planned[1].SQL)
+
+	assert.Empty(t, planned[2].ConditionSQL)
+	assert.Equal(t, `UPDATE "user" SET email = ''`, planned[2].SQL)
+	assert.False(t, planned[2].Destructive)
+
+	assert.True(t, planned[3].Destructive)
+
+	// nothing is executed
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDropRenamedTableMigration(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+	renamedAt := func(age time.Duration) string {
+		return "_drop_user_" + now.Add(-age).Format(droppedTableTimeFormat)
+	}
+	old, recent := renamedAt(10*24*time.Hour), renamedAt(time.Hour)
+
+	t.Run("drops tables renamed before the delay", func(t *testing.T) {
+		mg, mock := newTestMigrator(t)
+		mg.now = func() time.Time { return now }
+		mg.AddMigration("drop user", NewDropRenamedTableMigration("user", 7*24*time.Hour))
+
+		tables := func() *sqlmock.Rows {
+			return sqlmock.NewRows([]string{"tablename"}).AddRow("user_setting").AddRow("_drop_user_setting_20200101000000").AddRow(old)
+		}
+		expectMigrationLog(mock)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT tablename FROM pg_tables`).WillReturnRows(tables())
+		mock.ExpectQuery(`SELECT tablename FROM pg_tables`).WillReturnRows(tables())
+		mock.ExpectExec(`DROP TABLE IF EXISTS "` + old + `"`).WillReturnResult(sqlmock.NewResult(0, 0))
+		expectLogRecord(mock)
+		mock.ExpectCommit()
+		expectSync(mock)
+
+		_, err := mg.MigrateUp(context.Background())
+		require.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("stays pending within the recovery window", func(t *testing.T) {
+		mg, mock := newTestMigrator(t)
+		mg.now = func() time.Time { return now }
+		mg.AddMigration("drop user", NewDropRenamedTableMigration("user", 7*24*time.Hour))
+
+		// no log record, the next run checks again
+		expectMigrationLog(mock)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT tablename FROM pg_tables`).
+			WillReturnRows(sqlmock.NewRows([]string{"tablename"}).AddRow(old).AddRow(recent))
+		mock.ExpectCommit()
+		expectSync(mock)
+
+		results, err := mg.MigrateUp(context.Background())
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, SkipNotDue, results[0].SkipReason)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestConcurrentRefreshRunsOutsideTransaction(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+
+	m := NewRefreshMaterializedViewMigration("order_totals")
+	m.Concurrently = true
+	mg.AddMigration("refresh order totals", m)
+
+	expectMigrationLog(mock)
+	mock.ExpectExec(`REFRESH MATERIALIZED VIEW CONCURRENTLY "order_totals"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectLogRecord(mock)
+	expectSync(mock)
+
+	_, err := mg.MigrateUp(context.Background())
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAlterEnumTypeRunsOutsideTransactionBeforePostgres12(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+	mg.Dialect.(*Postgres).version = 110000
+	mg.AddMigration("add refunded status", NewAlterEnumTypeMigration("order_status", "refunded"))
+
+	expectMigrationLog(mock)
+	mock.ExpectQuery(`FROM pg_enum`).WithArgs("order_status", "refunded").
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}))
+	mock.ExpectExec(`ALTER TYPE "order_status" ADD VALUE IF NOT EXISTS 'refunded'`).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectLogRecord(mock)
+	expectSync(mock)
+
+	_, err := mg.MigrateUp(context.Background())
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRawSqlMigrationWithoutDialectSqlIsSkipped(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+
+	mg.AddMigration("mysql only", NewRawSqlMigration("").MySQL("ALTER TABLE user ENGINE=InnoDB"))
+
+	expectMigrationLog(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "migration_log"`).WithArgs("mysql only", "SELECT 1", true, "", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+	expectSync(mock)
+
+	results, err := mg.MigrateUp(context.Background())
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Zero(t, results[0].RowsAffected)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetSearchPathMigrationScopesFollowingMigrations(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+
+	mg.AddMigration("use tenant schema", NewSetSearchPathMigration("tenant", "public"))
+	mg.AddMigration("tenant settings", NewRawSqlMigration("UPDATE setting SET value = 1").AllowRepeat())
+	mg.AddMigration("tenant users", NewRawSqlMigration("UPDATE account SET value = 2").AllowRepeat())
+	mg.AddMigration("use default schema", NewSetSearchPathMigration())
+	mg.AddMigration("shared settings", NewRawSqlMigration("UPDATE setting SET value = 3").AllowRepeat())
+
+	// the search path applies to the following migrations also when it was
+	// set in an earlier run
+	expectMigrationLog(mock, "use tenant schema")
+	for _, sql := range []string{`UPDATE setting SET value = 1`, `UPDATE account SET value = 2`} {
+		mock.ExpectBegin()
+		mock.ExpectExec(`SET search_path TO "tenant", "public"`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(sql).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`SET search_path TO DEFAULT`).WillReturnResult(sqlmock.NewResult(0, 0))
+		expectLogRecord(mock)
+		mock.ExpectCommit()
+	}
+	// nothing is executed for the migration itself
+	mock.ExpectBegin()
+	expectLogRecord(mock)
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE setting SET value = 3`).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectLogRecord(mock)
+	mock.ExpectCommit()
+	expectSync(mock)
+
+	_, err := mg.MigrateUp(context.Background())
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResetDatabaseKeepsMigrationLog(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT tablename FROM pg_tables WHERE schemaname = current_schema\(\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"tablename"}).
+			AddRow("migration_log").AddRow("user").AddRow("order").AddRow("migration_checkpoint"))
+	mock.ExpectExec(`TRUNCATE "user", "order" RESTART IDENTITY CASCADE`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`INSERT INTO "migration_log"`).WithArgs(resetDatabaseMigrationID, "SELECT 1", true, "", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	require.NoError(t, mg.ResetDatabase(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSensitiveMigrationIsRecordedRedacted(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+
+	m := NewCreateRoleMigration("app").Login().Password("secret")
+	mg.AddMigration("create app role", m)
+
+	expectMigrationLog(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(`PASSWORD 'secret'`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`INSERT INTO "migration_log"`).WithArgs("create app role", m.RedactedSQL(mg.Dialect), true, "", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+	expectSync(mock)
+
+	_, err := mg.MigrateUp(context.Background())
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFuncMigrationRunsInTransaction(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+
+	mg.AddMigration("backfill user slugs", NewFuncMigration(func(ctx context.Context, sess *xorm.Session) error {
+		_, err := sess.Exec(`UPDATE "user" SET "slug" = ? WHERE "id" = ?`, "admin", 1)
+		return err
+	}))
+
+	expectMigrationLog(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "user" SET "slug"`).WithArgs("admin", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectLogRecord(mock)
+	mock.ExpectCommit()
+	expectSync(mock)
+
+	_, err := mg.MigrateUp(context.Background())
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrationHooksRunAroundMigration(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+
+	m := NewCopyTableDataMigration("user_copy", "user", map[string]string{"id": "id"})
+	m.Before("SET session_replication_role = replica")
+	m.After("SET session_replication_role = origin")
+	mg.AddMigration("copy user", m)
+
+	skipped := NewCreateStatisticsMigration("user_stats", Table{Name: "user"}, []string{"id", "login"})
+	skipped.Before("SET statement_timeout = 0")
+	mg.AddMigration("create user stats", skipped)
+
+	expectMigrationLog(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(`SET session_replication_role = replica`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "user_copy"`).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(`SET session_replication_role = origin`).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectLogRecord(mock)
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM pg_statistic_ext`).WithArgs("user_stats").
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+	expectLogRecord(mock)
+	mock.ExpectCommit()
+	expectSync(mock)
+
+	_, err := mg.MigrateUp(context.Background())
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIfColumnNotExistsConditionSkipsExistingColumn(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+
+	m := NewAddColumnMigration(Table{Name: "user"}, &Column{Name: "email", Type: DB_Text, Nullable: true})
+	m.Condition = &IfColumnNotExistsCondition{TableName: "user", ColumnName: "email"}
+	mg.AddMigration("add user email", m)
+
+	expectMigrationLog(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM information_schema.columns WHERE table_schema = current_schema\(\)`).WithArgs("user", "email").
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+	expectLogRecord(mock)
+	mock.ExpectCommit()
+	expectSync(mock)
+
+	_, err := mg.MigrateUp(context.Background())
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDropTableMigrationSkipsMissingTable(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+	mg.AddMigration("drop legacy table", NewDropTableMigration("legacy"))
+
+	expectMigrationLog(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM pg_tables`).WithArgs("legacy").
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}))
+	expectLogRecord(mock)
+	mock.ExpectCommit()
+	expectSync(mock)
+
+	results, err := mg.MigrateUp(context.Background())
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, SkipConditionFalse, results[0].SkipReason)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDropTableMigrationRenamesWithTimeOfRun(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+	mg.now = func() time.Time { return time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC) }
+
+	m := NewDropTableMigration("user")
+	m.RenameBeforeDrop = true
+	mg.AddMigration("drop user", m)
+
+	rename := `ALTER TABLE "user" RENAME TO "_drop_user_20260301123000"`
+	expectMigrationLog(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM pg_tables`).WithArgs("user").
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+	mock.ExpectExec(`ALTER TABLE "user" RENAME TO "_drop_user_20260301123000"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`INSERT INTO "migration_log"`).WithArgs("drop user", rename, true, "", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+	expectSync(mock)
+
+	_, err := mg.MigrateUp(context.Background())
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAndConditionSkipsWhenOneTableIsMissing(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+	mg.AddMigration("inherit event", NewInheritTableMigration("event_2024", "event"))
+	mg.AddMigration("inherit audit", NewInheritTableMigration("audit_2024", "audit"))
+
+	expectMigrationLog(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM pg_tables`).WithArgs("event_2024").
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+	mock.ExpectQuery(`FROM pg_tables`).WithArgs("event").
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}))
+	expectLogRecord(mock)
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM pg_tables`).WithArgs("audit_2024").
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+	mock.ExpectQuery(`FROM pg_tables`).WithArgs("audit").
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+	mock.ExpectExec(`ALTER TABLE "audit_2024" INHERIT "audit"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectLogRecord(mock)
+	mock.ExpectCommit()
+	expectSync(mock)
+
+	results, err := mg.MigrateUp(context.Background())
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, SkipConditionFalse, results[0].SkipReason)
+	assert.Empty(t, results[1].SkipReason)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConditionCheckIsLogged(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+	logCore, logs := observer.New(zap.DebugLevel)
+	mg.log = zap.New(logCore)
+
+	m := NewAddColumnMigration(Table{Name: "user"}, &Column{Name: "email", Type: DB_Text, Nullable: true})
+	m.Condition = &IfColumnNotExistsCondition{TableName: "user", ColumnName: "email"}
+	mg.AddMigration("add user email", m)
+
+	expectMigrationLog(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("user", "email").
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+	expectLogRecord(mock)
+	mock.ExpectCommit()
+	expectSync(mock)
+
+	_, err := mg.MigrateUp(context.Background())
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	checks := logs.FilterMessage("migration condition checked").All()
+	require.Len(t, checks, 1)
+	fields := checks[0].ContextMap()
+	assert.Equal(t, "add user email", fields["id"])
+	assert.Equal(t, "SELECT 1 FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?", fields["sql"])
+	assert.Equal(t, []interface{}{"user", "email"}, fields["args"])
+	assert.Equal(t, false, fields["fulfilled"])
+}
+
+func TestIfColumnsNotExistConditionRefusesPartiallyAddedColumns(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+
+	mg.AddMigration("add user address", NewAddColumnsMigration(Table{Name: "user"}, []*Column{
+		{Name: "city", Type: DB_Text, Nullable: true},
+		{Name: "zip", Type: DB_Text, Nullable: true},
+	}))
+
+	expectMigrationLog(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("user", "city").
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+	mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("user", "zip").
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}))
+	expectLogRecord(mock)
+	mock.ExpectRollback()
+
+	_, err := mg.MigrateUp(context.Background())
+	assert.ErrorContains(t, err, "columns city of table user exist already")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddNotNullColumnChecksForRows(t *testing.T) {
+	col := &Column{Name: "status", Type: DB_Text}
+
+	t.Run("empty table", func(t *testing.T) {
+		mg, mock := newTestMigrator(t)
+		mg.AddMigration("add user status", NewAddColumnMigration(Table{Name: "user"}, col))
+
+		expectMigrationLog(mock)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("user", "status").
+			WillReturnRows(sqlmock.NewRows([]string{"?column?"}))
+		mock.ExpectQuery(`SELECT 1 FROM "user" LIMIT 1`).WillReturnRows(sqlmock.NewRows([]string{"?column?"}))
+		mock.ExpectExec(`ADD COLUMN "status" TEXT NOT NULL`).WillReturnResult(sqlmock.NewResult(0, 0))
+		expectLogRecord(mock)
+		mock.ExpectCommit()
+		expectSync(mock)
+
+		_, err := mg.MigrateUp(context.Background())
+		require.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("table with rows", func(t *testing.T) {
+		mg, mock := newTestMigrator(t)
+		mg.AddMigration("add user status", NewAddColumnsMigration(Table{Name: "user"}, []*Column{
+			{Name: "city", Type: DB_Text, Nullable: true},
+			col,
+		}))
+
+		expectMigrationLog(mock)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("user", "city").
+			WillReturnRows(sqlmock.NewRows([]string{"?column?"}))
+		mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("user", "status").
+			WillReturnRows(sqlmock.NewRows([]string{"?column?"}))
+		mock.ExpectQuery(`SELECT 1 FROM "user" LIMIT 1`).WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+		expectLogRecord(mock)
+		mock.ExpectRollback()
+
+		_, err := mg.MigrateUp(context.Background())
+		assert.ErrorContains(t, err, "column user.status is NOT NULL without a default")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDropSchemaChecksForTables(t *testing.T) {
+	t.Run("empty schema", func(t *testing.T) {
+		mg, mock := newTestMigrator(t)
+		mg.AddMigration("drop tenant schema", NewDropSchemaMigration("tenant_1", false))
+
+		expectMigrationLog(mock)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT 1 FROM information_schema.tables WHERE table_schema = \$1 LIMIT 1`).WithArgs("tenant_1").
+			WillReturnRows(sqlmock.NewRows([]string{"?column?"}))
+		mock.ExpectExec(`DROP SCHEMA IF EXISTS "tenant_1"`).WillReturnResult(sqlmock.NewResult(0, 0))
+		expectLogRecord(mock)
+		mock.ExpectCommit()
+		expectSync(mock)
+
+		_, err := mg.MigrateUp(context.Background())
+		require.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("schema with tables", func(t *testing.T) {
+		mg, mock := newTestMigrator(t)
+		mg.AddMigration("drop tenant schema", NewDropSchemaMigration("tenant_1", false))
+
+		expectMigrationLog(mock)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT 1 FROM information_schema.tables WHERE table_schema = \$1 LIMIT 1`).WithArgs("tenant_1").
+			WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+		expectLogRecord(mock)
+		mock.ExpectRollback()
+
+		_, err := mg.MigrateUp(context.Background())
+		assert.ErrorContains(t, err, "schema tenant_1 is not empty")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("cascade", func(t *testing.T) {
+		mg, mock := newTestMigrator(t)
+		mg.AddMigration("drop tenant schema", NewDropSchemaMigration("tenant_1", true))
+
+		expectMigrationLog(mock)
+		mock.ExpectBegin()
+		mock.ExpectExec(`DROP SCHEMA IF EXISTS "tenant_1" CASCADE`).WillReturnResult(sqlmock.NewResult(0, 0))
+		expectLogRecord(mock)
+		mock.ExpectCommit()
+		expectSync(mock)
+
+		_, err := mg.MigrateUp(context.Background())
+		require.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDisableTriggersWrapsDataMigration(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+
+	m := NewCopyTableDataMigration("user_copy", "user", map[string]string{"id": "id"}).DisableTriggers()
+	m.After("ANALYZE \"user_copy\"")
+	mg.AddMigration("copy user", m)
+
+	expectMigrationLog(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(`SET session_replication_role = replica`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "user_copy"`).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(`ANALYZE "user_copy"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SET session_replication_role = origin`).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectLogRecord(mock)
+	mock.ExpectCommit()
+	expectSync(mock)
+
+	_, err := mg.MigrateUp(context.Background())
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDisableTriggersRollsBackOnFailure(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+
+	m := NewCopyTableDataMigration("user_copy", "user", map[string]string{"id": "id"}).DisableTriggers()
+	mg.AddMigration("copy user", m)
+
+	expectMigrationLog(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(`SET session_replication_role = replica`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "user_copy"`).WillReturnError(errors.New("duplicate key"))
+	// the rollback enables the triggers again
+	expectLogRecord(mock)
+	mock.ExpectRollback()
+
+	_, err := mg.MigrateUp(context.Background())
+	assert.ErrorContains(t, err, "duplicate key")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWithoutForeignKeyChecksLeftToRollbackOnFailure(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+
+	m := NewCopyTableDataMigration("order_copy", "order", map[string]string{"id": "id"})
+	m.WithoutForeignKeyChecks()
+	mg.AddMigration("copy order", m)
+
+	expectMigrationLog(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(`SET session_replication_role = replica`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "order_copy"`).WillReturnError(errors.New("duplicate key"))
+	// the transaction is aborted, the rollback restores the setting
+	expectLogRecord(mock)
+	mock.ExpectRollback()
+
+	_, err := mg.MigrateUp(context.Background())
+	assert.ErrorContains(t, err, "duplicate key")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWithoutForeignKeyChecksRestoresOnFailure(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+	mysql := &storageEngineDialect{Postgres: NewPostgresDialect(nil)}
+	mysql.BaseDialect.dialect = mysql
+	mg.Dialect = mysql
+
+	m := NewRawSqlMigration("DELETE FROM session WHERE expired").AllowRepeat()
+	m.WithoutForeignKeyChecks()
+	mg.AddMigration("delete stale sessions", m)
+
+	expectMigrationLog(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(`SET FOREIGN_KEY_CHECKS = 0`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM session WHERE expired`).WillReturnError(errors.New("lock wait timeout exceeded"))
+	// the rollback does not undo the setting, it is restored on the connection
+	// of the transaction
+	mock.ExpectExec(`SET FOREIGN_KEY_CHECKS = 1`).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectLogRecord(mock)
+	mock.ExpectRollback()
+
+	_, err := mg.MigrateUp(context.Background())
+	assert.ErrorContains(t, err, "lock wait timeout")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWithoutForeignKeyChecksRestoresOutsideTransaction(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+
+	m := NewRawSqlMigration(`DELETE FROM "order"`).OutsideTransaction().AllowRepeat()
+	m.WithoutForeignKeyChecks()
+	mg.AddMigration("purge orders", m)
+
+	expectMigrationLog(mock)
+	mock.ExpectExec(`SET session_replication_role = replica`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "order"`).WillReturnError(errors.New("canceling statement due to user request"))
+	// no rollback restores the setting of the session
+	mock.ExpectExec(`SET session_replication_role = origin`).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectLogRecord(mock)
+
+	_, err := mg.MigrateUp(context.Background())
+	assert.ErrorContains(t, err, "canceling statement")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigratorSearchPath(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+	mg.SearchPath = "tenant_1"
+
+	mg.AddMigration("create user table", NewAddTableMigration(Table{
+		Name:    "user",
+		Columns: []*Column{{Name: "id", Type: DB_BigInt, IsPrimaryKey: true}},
+	}))
+
+	expectMigrationLog(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(`SET search_path TO "tenant_1"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`FROM pg_tables WHERE schemaname = current_schema\(\) AND tablename`).WithArgs("user").
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "user"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SET search_path TO DEFAULT`).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectLogRecord(mock)
+	mock.ExpectCommit()
+	expectSync(mock)
+
+	_, err := mg.MigrateUp(context.Background())
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDialectConditionDoesNotQuery(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+
+	postgresOnly := NewRawSqlMigration("CREATE EXTENSION IF NOT EXISTS pg_trgm")
+	postgresOnly.Condition = &DialectCondition{Dialects: []string{POSTGRES}}
+	mg.AddMigration("add trigram extension", postgresOnly)
+
+	mysqlOnly := NewRawSqlMigration("ALTER TABLE user ENGINE=InnoDB")
+	mysqlOnly.Condition = &DialectCondition{Dialects: []string{MYSQL}}
+	mg.AddMigration("convert user table", mysqlOnly)
+
+	expectMigrationLog(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(`CREATE EXTENSION IF NOT EXISTS pg_trgm`).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectLogRecord(mock)
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	expectLogRecord(mock)
+	mock.ExpectCommit()
+	expectSync(mock)
+
+	_, err := mg.MigrateUp(context.Background())
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSingleTransactionRollsBackAllMigrations(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+	mg.SingleTransaction(true)
+
+	mg.AddMigration("create user table", NewAddTableMigration(Table{
+		Name:    "user",
+		Columns: []*Column{{Name: "id", Type: DB_BigInt, IsPrimaryKey: true}},
+	}))
+	mg.AddMigration("add user email", NewAddColumnMigration(Table{Name: "user"}, &Column{Name: "email", Type: DB_Text, Nullable: true}))
+
+	expectMigrationLog(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM pg_tables WHERE schemaname = current_schema\(\) AND tablename`).WithArgs("user").
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "user"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectLogRecord(mock)
+	mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("user", "email").
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}))
+	mock.ExpectExec(`ADD COLUMN "email"`).WillReturnError(errors.New("disk full"))
+	expectLogRecord(mock)
+	mock.ExpectRollback()
+
+	results, err := mg.MigrateUp(context.Background())
+	assert.ErrorContains(t, err, "disk full")
+	assert.Empty(t, results)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSingleTransactionRefusesNonTransactionalMigrations(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+	mg.SingleTransaction(true)
+
+	m := NewRefreshMaterializedViewMigration("order_totals")
+	m.Concurrently = true
+	mg.AddMigration("refresh order totals", m)
+
+	expectMigrationLog(mock)
+
+	_, err := mg.MigrateUp(context.Background())
+	assert.ErrorContains(t, err, "non-transactional")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLockTableMigrationNeedsSingleTransaction(t *testing.T) {
+	t.Run("refused in its own transaction", func(t *testing.T) {
+		mg, mock := newTestMigrator(t)
+		mg.AddMigration("lock order", NewLockTableMigration(Table{Name: "order"}, LockShareRowExclusive))
+
+		expectMigrationLog(mock)
+
+		_, err := mg.MigrateUp(context.Background())
+		assert.ErrorContains(t, err, "needs SingleTransaction")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("not refused without a lock for the dialect", func(t *testing.T) {
+		mg, mock := newTestMigrator(t)
+		mysql := &storageEngineDialect{Postgres: NewPostgresDialect(nil)}
+		mysql.BaseDialect.dialect = mysql
+		mg.Dialect = mysql
+		mg.AddMigration("lock order", NewLockTableMigration(Table{Name: "order"}, LockShareRowExclusive))
+
+		expectMigrationLog(mock)
+		mock.ExpectBegin()
+		expectLogRecord(mock)
+		mock.ExpectCommit()
+		expectSync(mock)
+
+		_, err := mg.MigrateUp(context.Background())
+		require.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("held for the following migrations", func(t *testing.T) {
+		mg, mock := newTestMigrator(t)
+		mg.SingleTransaction(true)
+		mg.AddMigration("lock order", NewLockTableMigration(Table{Name: "order"}, LockShareRowExclusive))
+		mg.AddMigration("backfill order total", NewRawSqlMigration(`UPDATE "order" SET "total" = 0 WHERE "total" IS NULL`).AllowRepeat())
+
+		expectMigrationLog(mock)
+		mock.ExpectBegin()
+		mock.ExpectExec(`LOCK TABLE "order" IN SHARE ROW EXCLUSIVE MODE`).WillReturnResult(sqlmock.NewResult(0, 0))
+		expectLogRecord(mock)
+		mock.ExpectExec(`UPDATE "order" SET "total" = 0`).WillReturnResult(sqlmock.NewResult(0, 2))
+		expectLogRecord(mock)
+		mock.ExpectCommit()
+		expectSync(mock)
+
+		_, err := mg.MigrateUp(context.Background())
+		require.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestLockTableForMigration(t *testing.T) {
+	t.Run("held in the transaction of the migration", func(t *testing.T) {
+		mg, mock := newTestMigrator(t)
+		m := NewRawSqlMigration(`UPDATE "order" SET "total" = 0 WHERE "total" IS NULL`).AllowRepeat()
+		m.LockTable(Table{Name: "order"}, LockShareRowExclusive)
+		mg.AddMigration("backfill order total", m)
+
+		expectMigrationLog(mock)
+		mock.ExpectBegin()
+		mock.ExpectExec(`LOCK TABLE "order" IN SHARE ROW EXCLUSIVE MODE`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`UPDATE "order" SET "total" = 0`).WillReturnResult(sqlmock.NewResult(0, 2))
+		expectLogRecord(mock)
+		mock.ExpectCommit()
+		expectSync(mock)
+
+		_, err := mg.MigrateUp(context.Background())
+		require.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("refused outside a transaction", func(t *testing.T) {
+		mg, mock := newTestMigrator(t)
+		m := NewRawSqlMigration(`UPDATE "order" SET "total" = 0 WHERE "total" IS NULL`).OutsideTransaction().AllowRepeat()
+		m.LockTable(Table{Name: "order"}, LockShareRowExclusive)
+		mg.AddMigration("backfill order total", m)
+
+		expectMigrationLog(mock)
+
+		_, err := mg.MigrateUp(context.Background())
+		assert.ErrorContains(t, err, "a table lock needs a transaction")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	assert.Panics(t, func() { NewRawSqlMigration("").LockTable(Table{Name: "order"}, "NO KEY UPDATE") })
+}
+
+func TestMigrateUpReportsSkipReasons(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+
+	mg.AddMigration("create user table", NewAddTableMigration(Table{
+		Name:    "user",
+		Columns: []*Column{{Name: "id", Type: DB_BigInt, IsPrimaryKey: true}},
+	}))
+	mg.AddMigration("create user stats", NewCreateStatisticsMigration("user_stats", Table{Name: "user"}, []string{"id", "login"}))
+	mg.AddMigration("backfill user data", NewRawSqlMigration(`UPDATE "user" SET "login" = lower("login")`))
+	mg.AddMigration("create order stats", NewCreateStatisticsMigration("order_stats", Table{Name: "order"}, []string{"id", "user_id"}))
+	mg.SetFilter(func(m Migration) bool {
+		return m.Id() != "backfill user data"
+	})
+
+	expectMigrationLog(mock, "create user table")
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM pg_statistic_ext`).WithArgs("user_stats").
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+	expectLogRecord(mock)
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM pg_statistic_ext`).WithArgs("order_stats").
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}))
+	mock.ExpectExec(`CREATE STATISTICS "order_stats"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectLogRecord(mock)
+	mock.ExpectCommit()
+	expectSync(mock)
+
+	results, err := mg.MigrateUp(context.Background())
+	require.NoError(t, err)
+
+	ids := make([]string, 0, len(results))
+	reasons := make([]SkipReason, 0, len(results))
+	for _, result := range results {
+		ids = append(ids, result.MigrationID)
+		reasons = append(reasons, result.SkipReason)
+	}
+	assert.Equal(t, []string{"create user table", "create user stats", "backfill user data", "create order stats"}, ids)
+	assert.Equal(t, []SkipReason{SkipAlreadyApplied, SkipConditionFalse, SkipFiltered, ""}, reasons)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDebugModeLogsMigrationTrace(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+	logCore, logs := observer.New(zap.DebugLevel)
+	mg.log = zap.New(logCore)
+	mg.DebugMode = true
+
+	mg.AddMigration("delete stale sessions", NewRawSqlMigration("DELETE FROM session WHERE expired"))
+
+	expectMigrationLog(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM session WHERE expired`).WillReturnResult(sqlmock.NewResult(0, 3))
+	expectLogRecord(mock)
+	mock.ExpectCommit()
+	expectSync(mock)
+
+	_, err := mg.MigrateUp(context.Background())
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	traces := logs.FilterMessage("migration trace").All()
+	require.Len(t, traces, 1)
+	fields := traces[0].ContextMap()
+	assert.Equal(t, "delete stale sessions", fields["id"])
+	assert.Equal(t, "DELETE FROM session WHERE expired", fields["sql"])
+	assert.Equal(t, int64(3), fields["rows_affected"])
+	assert.Contains(t, fields, "condition_duration")
+	assert.Contains(t, fields, "exec_duration")
+}
+
+func TestMigrateUpRejectsInvalidMigrationsUpfront(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+
+	mg.AddMigration("create role", NewRawSqlMigration("CREATE ROLE reader"))
+	mg.AddMigration("add user address", NewAddColumnsMigration(Table{Name: "user"}, nil))
+
+	expectMigrationLog(mock)
+
+	_, err := mg.MigrateUp(context.Background())
+	require.ErrorContains(t, err, "invalid migration add user address")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResetRerunsAllMigrations(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+
+	mg.AddMigration("create role", NewRawSqlMigration("CREATE ROLE reader"))
+
+	mock.ExpectExec(`DROP SCHEMA public CASCADE`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE SCHEMA public`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT tablename FROM pg_tables`).WillReturnRows(sqlmock.NewRows([]string{"tablename"}))
+	mock.ExpectBegin()
+	mock.ExpectExec(`CREATE ROLE reader`).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectLogRecord(mock)
+	mock.ExpectCommit()
+	expectSync(mock)
+
+	require.NoError(t, mg.Reset(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddTableMigrationSkipsExistingTable(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+
+	mg.AddMigration("create user table", NewAddTableMigration(Table{
+		Name:    "user",
+		Columns: []*Column{{Name: "id", Type: DB_BigInt, IsPrimaryKey: true}},
+	}))
+
+	expectMigrationLog(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM pg_tables WHERE schemaname = current_schema\(\) AND tablename`).WithArgs("user").
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+	expectLogRecord(mock)
+	mock.ExpectCommit()
+	expectSync(mock)
+
+	results, err := mg.MigrateUp(context.Background())
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, SkipConditionFalse, results[0].SkipReason)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrateNStopsAfterNMigrations(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+
+	mg.AddMigration("create role", NewRawSqlMigration("CREATE ROLE reader"))
+	mg.AddMigration("create extension", NewRawSqlMigration("CREATE EXTENSION IF NOT EXISTS pg_trgm"))
+	mg.AddMigration("grant reader", NewRawSqlMigration("GRANT SELECT ON ALL TABLES IN SCHEMA public TO reader"))
+
+	expectMigrationLog(mock, "create role")
+	mock.ExpectBegin()
+	mock.ExpectExec(`CREATE EXTENSION IF NOT EXISTS pg_trgm`).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectLogRecord(mock)
+	mock.ExpectCommit()
+	expectSync(mock)
+
+	require.NoError(t, mg.MigrateN(context.Background(), 1))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCopyTableDataWithVerify(t *testing.T) {
+	newMigrator := func(t *testing.T) (*Migrator, sqlmock.Sqlmock) {
+		mg, mock := newTestMigrator(t)
+		m := NewCopyTableDataMigration("user_copy", "user", map[string]string{"id": "id"})
+		m.WithVerify = true
+		mg.AddMigration("copy user", m)
+
+		expectMigrationLog(mock)
+		mock.ExpectBegin()
+		mock.ExpectExec(`INSERT INTO "user_copy"`).WillReturnResult(sqlmock.NewResult(0, 3))
+		return mg, mock
+	}
+
+	t.Run("matching counts commit the copy", func(t *testing.T) {
+		mg, mock := newMigrator(t)
+		mock.ExpectQuery(`SELECT COUNT\(\*\) AS count FROM "user"$`).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		expectLogRecord(mock)
+		mock.ExpectCommit()
+		expectSync(mock)
+
+		_, err := mg.MigrateUp(context.Background())
+		require.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("differing counts roll the copy back", func(t *testing.T) {
+		mg, mock := newMigrator(t)
+		mock.ExpectQuery(`SELECT COUNT\(\*\) AS count FROM "user"$`).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(4))
+		expectLogRecord(mock)
+		mock.ExpectRollback()
+
+		_, err := mg.MigrateUp(context.Background())
+		assert.ErrorContains(t, err, "4 source rows, 3 copied rows")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("chunks count the rows of their key range", func(t *testing.T) {
+		mg, mock := newTestMigrator(t)
+		m := NewCopyTableDataMigration("user_archive", "user", map[string]string{"user_id": "id"}).InChunks("id", 100)
+		m.WithVerify = true
+		mg.AddMigration("archive user", m)
+
+		expectMigrationLog(mock)
+		mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "migration_checkpoint"`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(`SELECT "checkpoint" FROM "migration_checkpoint"`).
+			WillReturnRows(sqlmock.NewRows([]string{"checkpoint"}))
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT MIN\("id"\) AS min_key, MAX\("id"\) AS max_key FROM "user"`).
+			WillReturnRows(sqlmock.NewRows([]string{"min_key", "max_key"}).AddRow(1, 50))
+		mock.ExpectExec(`INSERT INTO "user_archive"`).WithArgs(int64(0), int64(100)).WillReturnResult(sqlmock.NewResult(0, 48))
+		mock.ExpectQuery(`SELECT COUNT\(\*\) AS count FROM "user" WHERE "id" > \$1 AND "id" <= \$2`).WithArgs(int64(0), int64(100)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(50))
+		mock.ExpectRollback()
+
+		_, err := mg.MigrateUp(context.Background())
+		assert.ErrorContains(t, err, "50 source rows, 48 copied rows")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestConcurrentlyOutsideTransaction(t *testing.T) {
+	t.Run("raw sql in a transaction is refused upfront", func(t *testing.T) {
+		mg, mock := newTestMigrator(t)
+
+		mg.AddMigration("create role", NewRawSqlMigration("CREATE ROLE reader"))
+		mg.AddMigration("add login index", NewRawSqlMigration(`CREATE INDEX CONCURRENTLY "IDX_user_login" ON "user" ("login")`))
+
+		expectMigrationLog(mock)
+
+		_, err := mg.MigrateUp(context.Background())
+		var migrationErr *MigrationError
+		require.ErrorAs(t, err, &migrationErr)
+		assert.Equal(t, "add login index", migrationErr.MigrationID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("reindex in a transaction is refused upfront", func(t *testing.T) {
+		mg, mock := newTestMigrator(t)
+
+		mg.AddMigration("reindex user", NewRawSqlMigration(`REINDEX (VERBOSE) TABLE CONCURRENTLY "user"`).AllowRepeat())
+
+		expectMigrationLog(mock)
+
+		_, err := mg.MigrateUp(context.Background())
+		assert.ErrorContains(t, err, "CONCURRENTLY cannot run inside a transaction")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("refresh in a transaction is accepted", func(t *testing.T) {
+		mg, mock := newTestMigrator(t)
+
+		mg.AddMigration("refresh order totals", NewRawSqlMigration(`REFRESH MATERIALIZED VIEW CONCURRENTLY "order_totals"`).AllowRepeat())
+
+		expectMigrationLog(mock)
+		mock.ExpectBegin()
+		mock.ExpectExec(`REFRESH MATERIALIZED VIEW CONCURRENTLY "order_totals"`).WillReturnResult(sqlmock.NewResult(0, 0))
+		expectLogRecord(mock)
+		mock.ExpectCommit()
+		expectSync(mock)
+
+		_, err := mg.MigrateUp(context.Background())
+		require.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("concurrent index runs outside a transaction", func(t *testing.T) {
+		mg, mock := newTestMigrator(t)
+
+		mg.AddMigration("add login index", NewAddIndexMigration(Table{Name: "user"}, &Index{Cols: []string{"login"}}).Concurrently())
+
+		expectMigrationLog(mock)
+		mock.ExpectQuery(`FROM pg_indexes`).WithArgs("user", "IDX_user_login").
+			WillReturnRows(sqlmock.NewRows([]string{"?column?"}))
+		mock.ExpectExec(`CREATE INDEX CONCURRENTLY "IDX_user_login" ON "user" \("login"\)`).WillReturnResult(sqlmock.NewResult(0, 0))
+		expectLogRecord(mock)
+		expectSync(mock)
+
+		_, err := mg.MigrateUp(context.Background())
+		require.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestIfIndexExistsConditionMatchesExistingIndex(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+
+	mg.AddMigration("drop login index", NewDropIndexMigration(Table{Name: "user"}, &Index{Cols: []string{"login"}}))
+
+	expectMigrationLog(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT 1 FROM pg_indexes WHERE tablename = \$1 AND indexname = \$2`).WithArgs("user", "IDX_user_login").
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+	mock.ExpectExec(`DROP INDEX "IDX_user_login" CASCADE`).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectLogRecord(mock)
+	mock.ExpectCommit()
+	expectSync(mock)
+
+	results, err := mg.MigrateUp(context.Background())
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Empty(t, results[0].SkipReason)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSchemaSnapshotDecidesConditionsWithoutQueries(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+	mg.SchemaSnapshot = true
+
+	mg.AddMigration("create user table", NewAddTableMigration(Table{
+		Name:    "user",
+		Columns: []*Column{{Name: "id", Type: DB_BigInt, IsPrimaryKey: true}},
+	}))
+	mg.AddMigration("add user email", NewAddColumnMigration(Table{Name: "user"}, &Column{Name: "email", Type: DB_Text, Nullable: true}))
+	mg.AddMigration("add login index", NewAddIndexMigration(Table{Name: "user"}, &Index{Cols: []string{"login"}}))
+	mg.AddMigration("drop legacy index", NewDropIndexMigration(Table{Name: "user"}, &Index{Cols: []string{"legacy"}}))
+
+	expectMigrationLog(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT tablename FROM pg_tables WHERE schemaname = current_schema\(\)$`).
+		WillReturnRows(sqlmock.NewRows([]string{"tablename"}).AddRow("user"))
+	mock.ExpectQuery(`FROM information_schema.columns WHERE table_schema = current_schema\(\)$`).
+		WillReturnRows(sqlmock.NewRows([]string{"tablename", "columnname"}).AddRow("user", "id").AddRow("user", "email"))
+	mock.ExpectQuery(`FROM pg_indexes WHERE schemaname = current_schema\(\)$`).
+		WillReturnRows(sqlmock.NewRows([]string{"tablename", "indexname"}).AddRow("user", "IDX_user_login"))
+	expectLogRecord(mock)
+	mock.ExpectCommit()
+	for i := 0; i < 3; i++ {
+		mock.ExpectBegin()
+		expectLogRecord(mock)
+		mock.ExpectCommit()
+	}
+	expectSync(mock)
+
+	results, err := mg.MigrateUp(context.Background())
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+	for _, result := range results {
+		assert.Equal(t, SkipConditionFalse, result.SkipReason, result.MigrationID)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSchemaSnapshotIsReadPerSearchPath(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+	mg.SchemaSnapshot = true
+
+	table := Table{Name: "tag", Columns: []*Column{{Name: "id", Type: DB_BigInt, IsPrimaryKey: true}}}
+	mg.AddMigration("use tenant_1 schema", NewSetSearchPathMigration("tenant_1"))
+	mg.AddMigration("create tenant_1 tag table", NewAddTableMigration(table))
+	mg.AddMigration("use tenant_2 schema", NewSetSearchPathMigration("tenant_2"))
+	mg.AddMigration("create tenant_2 tag table", NewAddTableMigration(table))
+
+	expectSnapshot := func(tables ...string) {
+		rows := sqlmock.NewRows([]string{"tablename"})
+		for _, table := range tables {
+			rows.AddRow(table)
+		}
+		mock.ExpectQuery(`SELECT tablename FROM pg_tables WHERE schemaname = current_schema\(\)$`).WillReturnRows(rows)
+		mock.ExpectQuery(`FROM information_schema.columns WHERE table_schema = current_schema\(\)$`).
+			WillReturnRows(sqlmock.NewRows([]string{"tablename", "columnname"}))
+		mock.ExpectQuery(`FROM pg_indexes WHERE schemaname = current_schema\(\)$`).
+			WillReturnRows(sqlmock.NewRows([]string{"tablename", "indexname"}))
+	}
+
+	expectMigrationLog(mock)
+	mock.ExpectBegin()
+	expectLogRecord(mock)
+	mock.ExpectCommit()
+	// tenant_1 has the table, the migration is skipped
+	mock.ExpectBegin()
+	mock.ExpectExec(`SET search_path TO "tenant_1"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectSnapshot("tag")
+	mock.ExpectExec(`SET search_path TO DEFAULT`).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectLogRecord(mock)
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	expectLogRecord(mock)
+	mock.ExpectCommit()
+	// the snapshot of tenant_1 does not decide for tenant_2
+	mock.ExpectBegin()
+	mock.ExpectExec(`SET search_path TO "tenant_2"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectSnapshot()
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "tag"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SET search_path TO DEFAULT`).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectLogRecord(mock)
+	mock.ExpectCommit()
+	expectSync(mock)
+
+	results, err := mg.MigrateUp(context.Background())
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+	assert.Equal(t, SkipConditionFalse, results[1].SkipReason)
+	assert.Empty(t, results[3].SkipReason)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunFromStep(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+	mg.AddMigration("first", NewRawSqlMigration("UPDATE a SET x = 1").AllowRepeat())
+	mg.AddMigration("second", NewRawSqlMigration("UPDATE b SET x = 2").AllowRepeat())
+	mg.AddMigration("third", NewRawSqlMigration("UPDATE c SET x = 3").AllowRepeat())
+	mg.AddMigration("fourth", NewRawSqlMigration("UPDATE d SET x = 4").AllowRepeat())
+
+	// first is pending but skipped, fourth is applied already
+	expectMigrationLog(mock, "fourth")
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE c SET x = 3`).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectLogRecord(mock)
+	mock.ExpectCommit()
+	expectSync(mock)
+
+	require.NoError(t, mg.RunFromStep(context.Background(), "second"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.EqualError(t, mg.RunFromStep(context.Background(), "fifth"), "unknown migration: fifth")
+}
+
+func TestListPending(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+	mg.AddMigration("first", NewRawSqlMigration("UPDATE a SET x = 1"))
+	mg.AddMigration("second", NewRawSqlMigration("UPDATE b SET x = 2"))
+	mg.AddMigration("third", NewRawSqlMigration("UPDATE c SET x = 3"))
+
+	expectMigrationLog(mock, "second")
+
+	pending, err := mg.ListPending(context.Background())
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, "first", pending[0].Id())
+	assert.Equal(t, "third", pending[1].Id())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAppliedAt(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+	mg.AddMigration("first", NewRawSqlMigration("UPDATE a SET x = 1"))
+	mg.AddMigration("second", NewRawSqlMigration("UPDATE b SET x = 2"))
+
+	appliedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT tablename FROM pg_tables`).
+		WillReturnRows(sqlmock.NewRows([]string{"tablename"}).AddRow("migration_log"))
+	mock.ExpectQuery(`FROM "migration_log"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "migration_id", "sql", "success", "error", "timestamp"}).
+			AddRow(1, "first", "UPDATE a SET x = 1", true, "", appliedAt).
+			AddRow(2, "second", "UPDATE b SET x = 2", false, "syntax error", appliedAt))
+
+	ts, applied, err := mg.AppliedAt("first")
+	require.NoError(t, err)
+	assert.True(t, applied)
+	assert.True(t, appliedAt.Equal(ts))
+
+	expectMigrationLog(mock, "first")
+
+	ts, applied, err = mg.AppliedAt("second")
+	require.NoError(t, err)
+	assert.False(t, applied)
+	assert.True(t, ts.IsZero())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestVerify(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+	mg.AddMigration("first", NewRawSqlMigration("UPDATE a SET x = 1"))
+	mg.AddMigration("second", NewRawSqlMigration("UPDATE b SET x = 20"))
+	mg.AddMigration("third", NewRawSqlMigration("UPDATE c SET x = 3"))
+
+	mock.ExpectQuery(`SELECT tablename FROM pg_tables`).
+		WillReturnRows(sqlmock.NewRows([]string{"tablename"}).AddRow("migration_log"))
+	mock.ExpectQuery(`FROM "migration_log"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "migration_id", "sql", "success", "error", "timestamp"}).
+			AddRow(1, "first", "UPDATE a SET x = 1", true, "", time.Now()).
+			AddRow(2, "second", "UPDATE b SET x = 2", true, "", time.Now()))
+
+	err := mg.Verify(context.Background())
+	var verificationErr *VerificationError
+	require.ErrorAs(t, err, &verificationErr)
+	assert.Equal(t, []string{"second"}, verificationErr.MigrationIDs)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestVerifyAcceptsLogRowsOfEarlierVersions(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+
+	userTable := Table{
+		Name: "user",
+		Columns: []*Column{
+			{Name: "id", Type: DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "login_name", Type: DB_NVarchar, Length: 255, Nullable: false},
+		},
+	}
+	longIndex := &Index{Cols: []string{"login_name", "first_name", "middle_name", "last_name", "email", "created_at"}}
+	mg.AddMigration("create user table", NewAddTableMigration(userTable))
+	mg.AddMigration("add index user.login_name", NewAddIndexMigration(userTable, &Index{Cols: []string{"login_name"}, Type: UniqueIndex}))
+	mg.AddMigration("add long index", NewAddIndexMigration(userTable, longIndex))
+	mg.AddMigration("mysql only", NewRawSqlMigration("").MySQL("OPTIMIZE TABLE user"))
+	mg.AddMigration("versioned", NewRawSqlMigration("VACUUM").Set("postgres>=14", "VACUUM (PROCESS_TOAST)"))
+	mg.AddMigration("create audit event table", NewAddTableMigration(Table{
+		Schema: "audit",
+		Name:   "audit.event",
+		Columns: []*Column{
+			{Name: "id", Type: DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "payload", Type: DB_Text, Nullable: true},
+		},
+	}))
+
+	// as recorded before trailing semicolons were dropped, the no-op changed,
+	// long index names were shortened and statements were put on lines of
+	// their own
+	mock.ExpectQuery(`SELECT tablename FROM pg_tables`).
+		WillReturnRows(sqlmock.NewRows([]string{"tablename"}).AddRow("migration_log"))
+	mock.ExpectQuery(`FROM "migration_log"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "migration_id", "sql", "success", "error", "timestamp"}).
+			AddRow(1, "create user table", "CREATE TABLE IF NOT EXISTS \"user\" (\n\"id\" BIGSERIAL PRIMARY KEY  NOT NULL\n, \"login_name\" VARCHAR(255) NOT NULL\n);", true, "", time.Now()).
+			AddRow(2, "add index user.login_name", `CREATE UNIQUE INDEX "UQE_user_login_name" ON "user" ("login_name");`, true, "", time.Now()).
+			AddRow(3, "add long index", `CREATE INDEX "IDX_user_login_name_first_name_middle_name_last_name_email_created_at" ON "user" ("login_name","first_name","middle_name","last_name","email","created_at");`, true, "", time.Now()).
+			AddRow(4, "mysql only", "SELECT 0;", true, "", time.Now()).
+			AddRow(5, "versioned", "VACUUM", true, "", time.Now()).
+			AddRow(6, "create audit event table", "CREATE SCHEMA IF NOT EXISTS audit;CREATE TABLE IF NOT EXISTS audit.event (\n\"id\" BIGSERIAL PRIMARY KEY  NOT NULL\n, \"payload\" TEXT NULL\n);", true, "", time.Now()))
+
+	assert.NoError(t, mg.Verify(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMonitorMigrations(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+
+	mg.AddMigration("create role", NewRawSqlMigration("CREATE ROLE reader"))
+	mg.AddMigration("grant reader", NewRawSqlMigration("GRANT SELECT ON ALL TABLES IN SCHEMA public TO reader"))
+
+	expectMigrationLog(mock, "create role")
+	expectMigrationLog(mock, "create role", "grant reader")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updates := make(chan []MigrationStatus)
+	err := mg.MonitorMigrations(ctx, 10*time.Millisecond, func(statuses []MigrationStatus) {
+		select {
+		case updates <- statuses:
+		case <-ctx.Done():
+		}
+	})
+	require.NoError(t, err)
+
+	first := <-updates
+	require.Len(t, first, 2)
+	assert.True(t, first[0].Applied)
+	assert.False(t, first[1].Applied)
+
+	second := <-updates
+	cancel()
+	assert.True(t, second[1].Applied)
+	assert.Equal(t, "grant reader", second[1].MigrationID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Error(t, mg.MonitorMigrations(context.Background(), 0, func([]MigrationStatus) {}))
+}
+
+func TestRawSqlWithoutConditionGuard(t *testing.T) {
+	t.Run("strict mode refuses the migration", func(t *testing.T) {
+		mg, mock := newTestMigrator(t)
+		mg.StrictRawSql = true
+
+		mg.AddMigration("grant reader", NewRawSqlMigration("GRANT SELECT ON ALL TABLES IN SCHEMA public TO reader").AllowRepeat())
+		mg.AddMigration("bump prices", NewRawSqlMigration(`UPDATE "product" SET "price" = "price" * 1.1`))
+
+		expectMigrationLog(mock)
+
+		_, err := mg.MigrateUp(context.Background())
+		var migrationErr *MigrationError
+		require.ErrorAs(t, err, &migrationErr)
+		assert.Equal(t, "bump prices", migrationErr.MigrationID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("otherwise it is warned about", func(t *testing.T) {
+		mg, mock := newTestMigrator(t)
+		logCore, logs := observer.New(zap.WarnLevel)
+		mg.log = zap.New(logCore)
+
+		mg.AddMigration("bump prices", NewRawSqlMigration(`UPDATE "product" SET "price" = "price" * 1.1`))
+
+		expectMigrationLog(mock)
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "product"`).WillReturnResult(sqlmock.NewResult(0, 5))
+		expectLogRecord(mock)
+		mock.ExpectCommit()
+		expectSync(mock)
+
+		_, err := mg.MigrateUp(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, logs.FilterField(zap.String("id", "bump prices")).Len())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestUpsertDataMigration(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+
+	m := NewUpsertDataMigration("setting", []string{"key", "value"}, []string{"key"}, []string{"value"}).
+		Values("theme", "dark").
+		Values("locale", "en")
+	mg.AddMigration("seed settings", m)
+
+	upsert := `INSERT INTO "setting" \("key", "value"\) VALUES \(\$1, \$2\), \(\$3, \$4\) ON CONFLICT \("key"\) DO UPDATE SET "value" = EXCLUDED."value"`
+	expectMigrationLog(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(upsert).WithArgs("theme", "dark", "locale", "en").WillReturnResult(sqlmock.NewResult(0, 2))
+	expectLogRecord(mock)
+	mock.ExpectCommit()
+	expectSync(mock)
+
+	_, err := mg.MigrateUp(context.Background())
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Error(t, NewUpsertDataMigration("setting", []string{"key", "value"}, []string{"key"}, nil).Values("theme").Validate())
+	assert.Error(t, NewUpsertDataMigration("setting", []string{"key", "value"}, nil, []string{"value"}).Values("theme", "dark").Validate())
+	assert.Error(t, NewUpsertDataMigration("setting", nil, []string{"key"}, nil).Validate())
+	assert.Error(t, NewUpsertDataMigration("setting", []string{"value"}, []string{"key"}, nil).Validate())
+	assert.EqualError(t, NewUpsertDataMigration("setting", []string{"key", "value"}, []string{"key"}, []string{"value"}).
+		Values("theme", "dark").Values("locale", "en").Values("theme", "light").Validate(),
+		"rows 1 and 3 of setting have the same conflict key")
+}
+
+func TestBulkLoadMigration(t *testing.T) {
+	const rowCount = 5000
+
+	rows := make([][]interface{}, rowCount)
+	for i := range rows {
+		rows[i] = []interface{}{int64(i + 1), fmt.Sprintf("seed %d", i+1)}
+	}
+
+	t.Run("postgres copies the rows", func(t *testing.T) {
+		mg, mock := newTestMigrator(t)
+		mg.AddMigration("seed rows", NewBulkLoadMigration("seed", []string{"id", "name"}, rows))
+
+		expectMigrationLog(mock)
+		mock.ExpectBegin()
+		copyIn := mock.ExpectPrepare(`COPY "seed" \("id", "name"\) FROM STDIN`)
+		for _, row := range rows {
+			copyIn.ExpectExec().WithArgs(row[0], row[1]).WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+		copyIn.ExpectExec().WithoutArgs().WillReturnResult(sqlmock.NewResult(0, rowCount))
+		expectLogRecord(mock)
+		mock.ExpectCommit()
+		expectSync(mock)
+
+		start := time.Now()
+		_, err := mg.MigrateUp(context.Background())
+		require.NoError(t, err)
+		assert.Less(t, time.Since(start), 10*time.Second)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("other dialects insert in batches", func(t *testing.T) {
+		mg, mock := newTestMigrator(t)
+		mysql := NewPostgresDialect(nil)
+		mysql.BaseDialect.driverName = "mysql"
+		mg.Dialect = mysql
+		mg.AddMigration("seed rows", NewBulkLoadMigration("seed", []string{"id", "name"}, rows))
+
+		expectMigrationLog(mock)
+		mock.ExpectBegin()
+		for start := 0; start < rowCount; start += bulkLoadBatchSize {
+			args := make([]driver.Value, 0, 2*bulkLoadBatchSize)
+			for _, row := range rows[start : start+bulkLoadBatchSize] {
+				args = append(args, row[0], row[1])
+			}
+			mock.ExpectExec(`^INSERT INTO "seed" \("id"\s*, "name"\) VALUES \(\$1, \$2\), `).
+				WithArgs(args...).
+				WillReturnResult(sqlmock.NewResult(0, bulkLoadBatchSize))
+		}
+		expectLogRecord(mock)
+		mock.ExpectCommit()
+		expectSync(mock)
+
+		_, err := mg.MigrateUp(context.Background())
+		require.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	assert.Error(t, NewBulkLoadMigration("seed", []string{"id", "name"}, [][]interface{}{{1}}).Validate())
+}
+
+func TestPreflight(t *testing.T) {
+	newMigrator := func(t *testing.T, version string) (*Migrator, sqlmock.Sqlmock) {
+		db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+
+		engine, err := xorm.NewEngineWithDB(POSTGRES, "postgres://localhost:5432/test?sslmode=disable", core.FromDB(db))
+		require.NoError(t, err)
+
+		mock.ExpectPing()
+		mock.ExpectQuery(`SHOW server_version_num`).
+			WillReturnRows(sqlmock.NewRows([]string{"server_version_num"}).AddRow(version))
+
+		mg := NewMigrator(engine)
+		mg.MinServerVersion = 140000
+		return mg, mock
+	}
+
+	mg, mock := newMigrator(t, "130011")
+	err := mg.Preflight(context.Background())
+	assert.EqualError(t, err, "database server version 130011 is older than the required version 140000")
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	mg, mock = newMigrator(t, "150004")
+	assert.NoError(t, mg.Preflight(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	t.Run("unknown version", func(t *testing.T) {
+		db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+
+		engine, err := xorm.NewEngineWithDB(POSTGRES, "postgres://localhost:5432/test?sslmode=disable", core.FromDB(db))
+		require.NoError(t, err)
+
+		mock.ExpectPing()
+
+		mg := NewMigrator(engine)
+		mg.Dialect = &unknownVersionDialect{NewPostgresDialect(engine)}
+		mg.MinServerVersion = 140000
+		assert.NoError(t, mg.Preflight(context.Background()))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("failed read is not cached", func(t *testing.T) {
+		mg, mock := newTestMigrator(t)
+		mock.ExpectQuery(`SHOW server_version_num`).WillReturnError(errors.New("connection refused"))
+		mock.ExpectQuery(`SHOW server_version_num`).
+			WillReturnRows(sqlmock.NewRows([]string{"server_version_num"}).AddRow("150004"))
+
+		_, err := mg.Dialect.Version()
+		assert.ErrorContains(t, err, "connection refused")
+
+		version, err := mg.Dialect.Version()
+		require.NoError(t, err)
+		assert.Equal(t, 150004, version)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("canceled read is not cached", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		mg, mock := newTestMigrator(t)
+		mock.ExpectQuery(`SHOW server_version_num`).
+			WillReturnRows(sqlmock.NewRows([]string{"server_version_num"}).AddRow("150004"))
+
+		_, err := mg.Dialect.VersionContext(ctx)
+		assert.ErrorIs(t, err, context.Canceled)
+
+		version, err := mg.Dialect.Version()
+		require.NoError(t, err)
+		assert.Equal(t, 150004, version)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// unknownVersionDialect cannot tell the version of the server.
+type unknownVersionDialect struct {
+	*Postgres
+}
+
+func (d *unknownVersionDialect) VersionContext(ctx context.Context) (int, error) {
+	return 0, nil
+}
+
+func TestApplyOne(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+	mg.AddMigration("first", NewRawSqlMigration("UPDATE a SET x = 1").AllowRepeat())
+	mg.AddMigration("second", NewRawSqlMigration("UPDATE b SET x = 2").AllowRepeat())
+	mg.AddMigration("third", NewRawSqlMigration("UPDATE c SET x = 3").AllowRepeat())
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE b SET x = 2`).WillReturnResult(sqlmock.NewResult(0, 4))
+	expectLogRecord(mock)
+	mock.ExpectCommit()
+
+	require.NoError(t, mg.ApplyOne("second"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	err := mg.ApplyOne("fourth")
+	assert.EqualError(t, err, "unknown migration: fourth")
+}
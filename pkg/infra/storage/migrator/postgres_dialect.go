@@ -1,9 +1,16 @@
 package migrator
 
 import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/lib/pq"
 	"xorm.io/xorm"
@@ -11,6 +18,9 @@ import (
 
 type Postgres struct {
 	BaseDialect
+
+	versionMu sync.Mutex
+	version   int
 }
 
 func NewPostgresDialect(engine *xorm.Engine) *Postgres {
@@ -21,10 +31,42 @@ func NewPostgresDialect(engine *xorm.Engine) *Postgres {
 	return &d
 }
 
+// SupportEngine is false, Postgres has no storage engines.
 func (db *Postgres) SupportEngine() bool {
 	return false
 }
 
+func (db *Postgres) TransactionalSettings() bool {
+	return true
+}
+
+// CreateTableSql appends the storage parameters of the table, sorted by name.
+func (db *Postgres) CreateTableSql(table *Table) string {
+	sql := db.BaseDialect.CreateTableSql(table)
+	if table.Unlogged {
+		sql = strings.Replace(sql, "CREATE TABLE ", "CREATE UNLOGGED TABLE ", 1)
+	}
+	if table.LikeTable != "" || len(table.StorageParams) == 0 {
+		return sql
+	}
+
+	params := make([]string, 0, len(table.StorageParams))
+	for _, name := range slices.Sorted(maps.Keys(table.StorageParams)) {
+		params = append(params, name+"="+table.StorageParams[name])
+	}
+
+	return sql + " WITH (" + strings.Join(params, ", ") + ")"
+}
+
+// AddColumnsSql adds all columns in a single ALTER TABLE.
+func (db *Postgres) AddColumnsSql(tableName string, cols []*Column) string {
+	clauses := make([]string, 0, len(cols))
+	for _, col := range cols {
+		clauses = append(clauses, "ADD COLUMN "+strings.TrimSpace(col.StringNoPk(db)))
+	}
+	return fmt.Sprintf("ALTER TABLE %s %s", db.Quote(tableName), strings.Join(clauses, ", "))
+}
+
 func (db *Postgres) Quote(name string) string {
 	return "\"" + name + "\""
 }
@@ -42,15 +84,33 @@ func (db *Postgres) BooleanStr(value bool) string {
 }
 
 func (b *Postgres) Default(col *Column) string {
+	if col.IsDefaultNull {
+		return "NULL"
+	}
 	if col.Type == DB_Bool {
 		if col.Default == "0" {
 			return "FALSE"
 		}
 		return "TRUE"
 	}
+	if col.Type == DB_JSONB {
+		return jsonbDefault(col.Default)
+	}
 	return col.Default
 }
 
+func jsonbDefault(value string) string {
+	literal := value
+	if len(literal) >= 2 && strings.HasPrefix(literal, "'") && strings.HasSuffix(literal, "'") {
+		literal = strings.ReplaceAll(literal[1:len(literal)-1], "''", "'")
+	}
+
+	if !json.Valid([]byte(literal)) {
+		return value
+	}
+	return quoteLiteral(literal) + "::jsonb"
+}
+
 func (db *Postgres) SqlType(c *Column) string {
 	var res string
 	switch t := c.Type; t {
@@ -86,6 +146,13 @@ func (db *Postgres) SqlType(c *Column) string {
 		return "DOUBLE PRECISION"
 	case DB_JSON:
 		res = DB_JSON
+	case DB_Point, DB_Line, DB_LSeg, DB_Box, DB_Path, DB_Polygon, DB_Circle:
+		return t
+	case DB_Interval:
+		if c.IntervalPrecision > 0 {
+			return DB_Interval + "(" + strconv.Itoa(c.IntervalPrecision) + ")"
+		}
+		return DB_Interval
 	default:
 		if c.IsAutoIncrement {
 			return DB_BigSerial
@@ -103,30 +170,662 @@ func (db *Postgres) SqlType(c *Column) string {
 	return res
 }
 
+func (db *Postgres) CreateTableLikeSql(table *Table) string {
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (LIKE %s INCLUDING ALL)", db.Quote(table.Name), db.Quote(table.LikeTable))
+}
+
 func (db *Postgres) IndexCheckSql(tableName, indexName string) (string, []interface{}) {
 	args := []interface{}{tableName, indexName}
-	sql := "SELECT 1 FROM " + db.Quote("pg_indexes") + " WHERE" + db.Quote("tablename") + "=? AND " + db.Quote("indexname") + "=?"
+	sql := "SELECT 1 FROM pg_indexes WHERE tablename = ? AND indexname = ?"
+	return sql, args
+}
+
+func (db *Postgres) ColumnCheckSql(tableName, columnName string) (string, []interface{}) {
+	args := []interface{}{tableName, columnName}
+	sql := "SELECT 1 FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?"
+	return sql, args
+}
+
+func (db *Postgres) StatisticsCheckSql(name string) (string, []interface{}) {
+	args := []interface{}{name}
+	sql := "SELECT 1 FROM pg_statistic_ext WHERE stxname = ?"
 	return sql, args
 }
 
+func (db *Postgres) CreateStatisticsSql(name string, tableName string, columns []string, kinds []string) string {
+	quotedCols := make([]string, 0, len(columns))
+	for _, col := range columns {
+		quotedCols = append(quotedCols, db.Quote(col))
+	}
+
+	var kindsSql string
+	if len(kinds) > 0 {
+		kindsSql = " (" + strings.Join(kinds, ", ") + ")"
+	}
+
+	return fmt.Sprintf("CREATE STATISTICS %s%s ON %s FROM %s", db.Quote(name), kindsSql, strings.Join(quotedCols, ", "), db.Quote(tableName))
+}
+
+func (db *Postgres) DropStatisticsSql(name string) string {
+	return fmt.Sprintf("DROP STATISTICS IF EXISTS %s", db.Quote(name))
+}
+
+func (db *Postgres) RefreshMaterializedViewSql(viewName string, concurrently bool) string {
+	if concurrently {
+		return fmt.Sprintf("REFRESH MATERIALIZED VIEW CONCURRENTLY %s", db.Quote(viewName))
+	}
+
+	return fmt.Sprintf("REFRESH MATERIALIZED VIEW %s", db.Quote(viewName))
+}
+
+// DependentViewsSql lists the views built on the table, dependencies first.
+func (db *Postgres) DependentViewsSql(tableName string) (string, []interface{}) {
+	args := []interface{}{db.Quote(tableName)}
+	sql := `WITH RECURSIVE dependent(oid, depth) AS (
+			SELECT r.ev_class, 1
+			FROM pg_depend d
+			JOIN pg_rewrite r ON r.oid = d.objid
+			WHERE d.refclassid = 'pg_class'::regclass AND d.refobjid = ?::regclass AND r.ev_class <> d.refobjid
+			UNION
+			SELECT r.ev_class, dependent.depth + 1
+			FROM dependent
+			JOIN pg_depend d ON d.refobjid = dependent.oid
+			JOIN pg_rewrite r ON r.oid = d.objid
+			WHERE d.refclassid = 'pg_class'::regclass AND r.ev_class <> dependent.oid
+		)
+		SELECT n.nspname AS schema, v.relname AS name, v.relkind AS kind, pg_get_viewdef(v.oid) AS definition
+		FROM (SELECT oid, max(depth) AS depth FROM dependent GROUP BY oid) dep
+		JOIN pg_class v ON v.oid = dep.oid
+		JOIN pg_namespace n ON n.oid = v.relnamespace
+		WHERE v.relkind IN ('v', 'm')
+		ORDER BY dep.depth, n.nspname, v.relname`
+	return sql, args
+}
+
+// InboundForeignKeysSql lists the foreign keys of other tables referencing the table.
+func (db *Postgres) InboundForeignKeysSql(tableName string) (string, []interface{}) {
+	args := []interface{}{db.Quote(tableName)}
+	sql := `SELECT n.nspname AS schema, t.relname AS table_name, c.conname AS name, pg_get_constraintdef(c.oid) AS definition
+		FROM pg_constraint c
+		JOIN pg_class t ON t.oid = c.conrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		WHERE c.contype = 'f' AND c.confrelid = ?::regclass AND c.conrelid <> c.confrelid
+		ORDER BY n.nspname, t.relname, c.conname`
+	return sql, args
+}
+
+func (db *Postgres) BulkUpsertSql(tableName string, cols []string, conflictCols []string, updateCols []string, rowCount int) string {
+	quoteCols := func(cols []string) string {
+		quoted := make([]string, 0, len(cols))
+		for _, col := range cols {
+			quoted = append(quoted, db.Quote(col))
+		}
+		return strings.Join(quoted, ", ")
+	}
+
+	sql := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s ON CONFLICT (%s)",
+		db.Quote(tableName), quoteCols(cols), rowPlaceholders(len(cols), rowCount), quoteCols(conflictCols))
+
+	if len(updateCols) == 0 {
+		return sql + " DO NOTHING"
+	}
+
+	updates := make([]string, 0, len(updateCols))
+	for _, col := range updateCols {
+		updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", db.Quote(col), db.Quote(col)))
+	}
+
+	return sql + " DO UPDATE SET " + strings.Join(updates, ", ")
+}
+
+// CreateIndexSql renders the operator classes of the index columns.
+func (db *Postgres) CreateIndexSql(tableName string, index *Index) string {
+	return db.createIndexSql(tableName, index, func(col string) string {
+		if opClass := index.OperatorClasses[col]; opClass != "" {
+			return db.Quote(col) + " " + opClass
+		}
+		return db.Quote(col)
+	})
+}
+
+func (db *Postgres) CreateIndexConcurrentlySql(tableName string, index *Index) string {
+	return strings.Replace(db.CreateIndexSql(tableName, index), " INDEX ", " INDEX CONCURRENTLY ", 1)
+}
+
+// VersionContext reads server_version_num and caches it once it was read.
+func (db *Postgres) VersionContext(ctx context.Context) (int, error) {
+	db.versionMu.Lock()
+	defer db.versionMu.Unlock()
+
+	if db.version > 0 {
+		return db.version, nil
+	}
+
+	version, err := db.readVersion(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	db.version = version
+	return version, nil
+}
+
+func (db *Postgres) readVersion(ctx context.Context) (int, error) {
+	if db.engine == nil {
+		return 0, errors.New("no database to read the version from")
+	}
+
+	results, err := db.engine.Context(ctx).QueryString("SHOW server_version_num")
+	if err != nil {
+		return 0, fmt.Errorf("%v: %w", "failed to read server version", err)
+	}
+
+	if len(results) == 0 {
+		return 0, errors.New("server version not reported")
+	}
+
+	version, err := strconv.Atoi(results[0]["server_version_num"])
+	if err != nil {
+		return 0, fmt.Errorf("invalid server version %q: %w", results[0]["server_version_num"], err)
+	}
+
+	return version, nil
+}
+
+// MaxIdentifierLength is NAMEDATALEN - 1.
+func (db *Postgres) MaxIdentifierLength() int {
+	return 63
+}
+
 func (db *Postgres) DropIndexSql(tableName string, index *Index) string {
 	quote := db.Quote
-	idxName := index.XName(tableName)
+	idxName := index.XNameWithLimit(tableName, db.MaxIdentifierLength())
 	return fmt.Sprintf("DROP INDEX %v CASCADE", quote(idxName))
 }
 
+func (db *Postgres) RenameIndexSql(oldTableName string, newTableName string, index *Index) string {
+	idx := *index
+	maxLength := db.MaxIdentifierLength()
+	oldName, newName := idx.XNameWithLimit(oldTableName, maxLength), idx.XNameWithLimit(newTableName, maxLength)
+	if oldName == newName {
+		return ""
+	}
+
+	return fmt.Sprintf("ALTER INDEX IF EXISTS %s RENAME TO %s", db.Quote(oldName), db.Quote(newName))
+}
+
+func (db *Postgres) RenameSequenceSql(oldTableName string, newTableName string, columnName string) string {
+	oldName := fmt.Sprintf("%s_%s_seq", oldTableName, columnName)
+	newName := fmt.Sprintf("%s_%s_seq", newTableName, columnName)
+	return fmt.Sprintf("ALTER SEQUENCE IF EXISTS %s RENAME TO %s", db.Quote(oldName), db.Quote(newName))
+}
+
+func (db *Postgres) InheritTableSql(childTable string, parentTable string) string {
+	return fmt.Sprintf("ALTER TABLE %s INHERIT %s", db.Quote(childTable), db.Quote(parentTable))
+}
+
+func (db *Postgres) NoInheritTableSql(childTable string, parentTable string) string {
+	return fmt.Sprintf("ALTER TABLE %s NO INHERIT %s", db.Quote(childTable), db.Quote(parentTable))
+}
+
+// ResetSequenceSql moves the serial sequence past the highest value of the column.
+func (db *Postgres) ResetSequenceSql(tableName string, columnName string) string {
+	return fmt.Sprintf("SELECT setval(pg_get_serial_sequence(%s, %s), COALESCE(MAX(%s), 0) + 1, false) FROM %s",
+		quoteLiteral(db.Quote(tableName)), quoteLiteral(columnName), db.Quote(columnName), db.Quote(tableName))
+}
+
 func (db *Postgres) DropColumnSql(tableName string, col *Column) string {
-	return fmt.Sprintf("ALTER TABLE %s  DROP COLUMN %s ;", db.dialect.Quote(tableName), db.Quote(col.Name))
+	return fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", db.Quote(tableName), db.Quote(col.Name))
+}
+
+func (db *Postgres) DropColumnIfExistsSql(tableName string, col *Column) string {
+	return fmt.Sprintf("ALTER TABLE %s DROP COLUMN IF EXISTS %s", db.Quote(tableName), db.Quote(col.Name))
+}
+
+func (db *Postgres) DropColumnsIfExistSql(tableName string, columnNames []string) string {
+	clauses := make([]string, 0, len(columnNames))
+	for _, name := range columnNames {
+		clauses = append(clauses, "DROP COLUMN IF EXISTS "+db.Quote(name))
+	}
+	return fmt.Sprintf("ALTER TABLE %s %s", db.Quote(tableName), strings.Join(clauses, ", "))
+}
+
+func (db *Postgres) SetNotNullSql(tableName string, col *Column) string {
+	return fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET NOT NULL", db.Quote(tableName), db.Quote(col.Name))
+}
+
+// DropPrimaryKeySql drops the constraint, <table>_pkey unless named.
+func (db *Postgres) DropPrimaryKeySql(tableName string, constraintName string) string {
+	if constraintName == "" {
+		constraintName = tableName + "_pkey"
+	}
+
+	return fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", db.Quote(tableName), db.Quote(constraintName))
+}
+
+// DisableTriggersSql makes the session act as a replica.
+func (db *Postgres) DisableTriggersSql() string {
+	return "SET session_replication_role = replica"
+}
+
+func (db *Postgres) EnableTriggersSql() string {
+	return "SET session_replication_role = origin"
+}
+
+func (db *Postgres) DropConstraintSql(tableName string, constraintName string, ifExists bool) string {
+	var ifExistsSql string
+	if ifExists {
+		ifExistsSql = "IF EXISTS "
+	}
+
+	return fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s%s", db.Quote(tableName), ifExistsSql, db.Quote(constraintName))
+}
+
+func (db *Postgres) RenameConstraintSql(tableName string, oldName string, newName string) string {
+	return fmt.Sprintf("ALTER TABLE %s RENAME CONSTRAINT %s TO %s", db.Quote(tableName), db.Quote(oldName), db.Quote(newName))
+}
+
+// DropForeignKeySql drops the foreign key constraint.
+func (db *Postgres) DropForeignKeySql(tableName string, fkName string, ifExists bool) string {
+	return db.DropConstraintSql(tableName, fkName, ifExists)
+}
+
+// DisableForeignKeyChecksSql relies on replica mode as well.
+func (db *Postgres) DisableForeignKeyChecksSql() string {
+	return db.DisableTriggersSql()
+}
+
+func (db *Postgres) EnableForeignKeyChecksSql() string {
+	return db.EnableTriggersSql()
+}
+
+// SetSearchPathSql sets the search path, restoring the default without schemas.
+func (db *Postgres) SetSearchPathSql(schemas []string, local bool) string {
+	scope := ""
+	if local {
+		scope = "LOCAL "
+	}
+
+	if len(schemas) == 0 {
+		return fmt.Sprintf("SET %ssearch_path TO DEFAULT", scope)
+	}
+
+	quoted := make([]string, 0, len(schemas))
+	for _, schema := range schemas {
+		quoted = append(quoted, db.Quote(schema))
+	}
+
+	return fmt.Sprintf("SET %ssearch_path TO %s", scope, strings.Join(quoted, ", "))
+}
+
+func (db *Postgres) CreateSchemaSql(name string) string {
+	return fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", db.Quote(name))
+}
+
+func (db *Postgres) DropSchemaSql(name string, cascade bool) string {
+	sql := fmt.Sprintf("DROP SCHEMA IF EXISTS %s", db.Quote(name))
+	if cascade {
+		sql += " CASCADE"
+	}
+
+	return sql
+}
+
+// CommentOnIndexSql sets the comment of the index, an empty comment removes it.
+func (db *Postgres) CommentOnIndexSql(tableName string, indexName string, comment string) string {
+	value := "NULL"
+	if comment != "" {
+		value = quoteLiteral(comment)
+	}
+
+	return fmt.Sprintf("COMMENT ON INDEX %s IS %s", db.Quote(indexName), value)
+}
+
+// CommentOnTableSql sets the comment of the table, an empty comment removes it.
+func (db *Postgres) CommentOnTableSql(tableName string, comment string) string {
+	value := "NULL"
+	if comment != "" {
+		value = quoteLiteral(comment)
+	}
+
+	return fmt.Sprintf("COMMENT ON TABLE %s IS %s", db.Quote(tableName), value)
+}
+
+func (db *Postgres) TableCheckSql(tableName string) (string, []interface{}) {
+	args := []interface{}{tableName}
+	sql := "SELECT 1 FROM pg_tables WHERE schemaname = current_schema() AND tablename = ?"
+	return sql, args
+}
+
+func (db *Postgres) ConstraintCheckSql(tableName, constraintName string) (string, []interface{}) {
+	args := []interface{}{tableName, constraintName}
+	sql := "SELECT 1 FROM information_schema.table_constraints WHERE table_schema = current_schema() AND table_name = ? AND constraint_name = ?"
+	return sql, args
+}
+
+// EnumValueCheckSql looks at the type visible on the search path.
+func (db *Postgres) EnumValueCheckSql(typeName, value string) (string, []interface{}) {
+	args := []interface{}{typeName, value}
+	sql := "SELECT 1 FROM pg_enum e JOIN pg_type t ON t.oid = e.enumtypid WHERE t.typname = ? AND pg_type_is_visible(t.oid) AND e.enumlabel = ?"
+	return sql, args
+}
+
+func (db *Postgres) TablesSql() (string, []interface{}) {
+	return "SELECT tablename FROM pg_tables WHERE schemaname = current_schema()", nil
+}
+
+func (db *Postgres) ColumnsSql() (string, []interface{}) {
+	return "SELECT table_name AS tablename, column_name AS columnname FROM information_schema.columns WHERE table_schema = current_schema()", nil
+}
+
+func (db *Postgres) IndexesSql() (string, []interface{}) {
+	return "SELECT tablename, indexname FROM pg_indexes WHERE schemaname = current_schema()", nil
+}
+
+// TruncateTablesSql empties the tables in one statement.
+func (db *Postgres) TruncateTablesSql(tableNames []string) string {
+	if len(tableNames) == 0 {
+		return ""
+	}
+
+	quoted := make([]string, 0, len(tableNames))
+	for _, name := range tableNames {
+		quoted = append(quoted, db.Quote(name))
+	}
+
+	return fmt.Sprintf("TRUNCATE %s RESTART IDENTITY CASCADE", strings.Join(quoted, ", "))
+}
+
+// CreateRoleSql creates the role unless it exists already.
+func (db *Postgres) CreateRoleSql(role *Role) string {
+	stmt := "CREATE ROLE " + db.Quote(role.Name)
+	if role.Login {
+		stmt += " LOGIN"
+	}
+
+	if role.Password != "" {
+		stmt += " PASSWORD " + quoteLiteral(role.Password)
+	}
+
+	if len(role.InRoles) > 0 {
+		quoted := make([]string, 0, len(role.InRoles))
+		for _, parent := range role.InRoles {
+			quoted = append(quoted, db.Quote(parent))
+		}
+		stmt += " IN ROLE " + strings.Join(quoted, ", ")
+	}
+
+	return fmt.Sprintf(`DO $$
+BEGIN
+	IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = %s) THEN
+		%s;
+	END IF;
+END
+$$`, quoteLiteral(role.Name), stmt)
+}
+
+func (db *Postgres) CreateEventTriggerSql(trigger *EventTrigger) string {
+	sql := fmt.Sprintf("CREATE EVENT TRIGGER %s ON %s", db.Quote(trigger.Name), trigger.Event)
+	if len(trigger.Tags) > 0 {
+		tags := make([]string, 0, len(trigger.Tags))
+		for _, tag := range trigger.Tags {
+			tags = append(tags, quoteLiteral(tag))
+		}
+		sql += " WHEN TAG IN (" + strings.Join(tags, ", ") + ")"
+	}
+
+	return sql + fmt.Sprintf(" EXECUTE FUNCTION %s()", db.quoteFunction(trigger.Function))
+}
+
+func (db *Postgres) DropEventTriggerSql(name string) string {
+	return fmt.Sprintf("DROP EVENT TRIGGER IF EXISTS %s", db.Quote(name))
+}
+
+// CreateCastSql leaves out AS EXPLICIT, which Postgres does not have.
+func (db *Postgres) CreateCastSql(cast *Cast) string {
+	sql := fmt.Sprintf("CREATE CAST (%s AS %s) WITH FUNCTION %s(%s)",
+		cast.Source, cast.Target, db.quoteFunction(cast.Function), cast.Source)
+	if cast.Context == CastAssignment || cast.Context == CastImplicit {
+		sql += " AS " + cast.Context
+	}
+
+	return sql
+}
+
+func (db *Postgres) CreateAggregateSql(aggregate *Aggregate) string {
+	var orReplace string
+	if aggregate.OrReplace {
+		orReplace = " OR REPLACE"
+	}
+
+	options := []string{"SFUNC = " + db.quoteFunction(aggregate.SFunc), "STYPE = " + aggregate.SType}
+	if aggregate.FinalFunc != "" {
+		options = append(options, "FINALFUNC = "+db.quoteFunction(aggregate.FinalFunc))
+	}
+	if aggregate.InitCond != "" {
+		options = append(options, "INITCOND = "+quoteLiteral(aggregate.InitCond))
+	}
+
+	return fmt.Sprintf("CREATE%s AGGREGATE %s (%s) (%s)", orReplace, db.quoteFunction(aggregate.Name),
+		aggregateArgs(aggregate.Args), strings.Join(options, ", "))
+}
+
+func aggregateArgs(args []string) string {
+	if len(args) == 0 {
+		return "*"
+	}
+	return strings.Join(args, ", ")
+}
+
+func (db *Postgres) quoteFunction(name string) string {
+	var args string
+	if i := strings.Index(name, "("); i >= 0 {
+		name, args = name[:i], name[i:]
+	}
+
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = db.Quote(part)
+	}
+
+	return strings.Join(parts, ".") + args
+}
+
+// CreatePublicationSql publishes all tables when tables is nil.
+func (db *Postgres) CreatePublicationSql(name string, tables []string) string {
+	if tables == nil {
+		return fmt.Sprintf("CREATE PUBLICATION %s FOR ALL TABLES", db.Quote(name))
+	}
+	if len(tables) == 0 {
+		return fmt.Sprintf("CREATE PUBLICATION %s", db.Quote(name))
+	}
+
+	quoted := make([]string, len(tables))
+	for i, table := range tables {
+		quoted[i] = db.Quote(table)
+	}
+
+	return fmt.Sprintf("CREATE PUBLICATION %s FOR TABLE %s", db.Quote(name), strings.Join(quoted, ", "))
+}
+
+func (db *Postgres) DropPublicationSql(name string) string {
+	return fmt.Sprintf("DROP PUBLICATION IF EXISTS %s", db.Quote(name))
+}
+
+func (db *Postgres) AddTableToPublicationSql(publication string, table string) string {
+	return fmt.Sprintf("ALTER PUBLICATION %s ADD TABLE %s", db.Quote(publication), db.Quote(table))
+}
+
+func (db *Postgres) DropTableFromPublicationSql(publication string, table string) string {
+	return fmt.Sprintf("ALTER PUBLICATION %s DROP TABLE %s", db.Quote(publication), db.Quote(table))
+}
+
+func (db *Postgres) CreateOperatorSql(operator *Operator) string {
+	options := []string{"FUNCTION = " + db.quoteFunction(operator.Function)}
+	if operator.LeftArg != "" {
+		options = append(options, "LEFTARG = "+operator.LeftArg)
+	}
+	options = append(options, "RIGHTARG = "+operator.RightArg)
+	if operator.Commutator != "" {
+		options = append(options, "COMMUTATOR = "+operator.Commutator)
+	}
+	if operator.Negator != "" {
+		options = append(options, "NEGATOR = "+operator.Negator)
+	}
+
+	return fmt.Sprintf("CREATE OPERATOR %s (%s)", operator.Name, strings.Join(options, ", "))
+}
+
+// DropOperatorSql drops a prefix operator when leftArg is empty.
+func (db *Postgres) DropOperatorSql(name string, leftArg string, rightArg string) string {
+	if leftArg == "" {
+		leftArg = "NONE"
+	}
+
+	return fmt.Sprintf("DROP OPERATOR IF EXISTS %s (%s, %s)", name, leftArg, rightArg)
+}
+
+func (db *Postgres) CreateOperatorClassSql(opClass *OperatorClass) string {
+	var defaultSql string
+	if opClass.Default {
+		defaultSql = " DEFAULT"
+	}
+
+	items := make([]string, 0, len(opClass.Members)+1)
+	for _, member := range opClass.Members {
+		if member.Operator != "" {
+			items = append(items, fmt.Sprintf("OPERATOR %d %s", member.Number, member.Operator))
+		} else {
+			items = append(items, fmt.Sprintf("FUNCTION %d %s", member.Number, db.quoteFunction(member.Function)))
+		}
+	}
+	if opClass.Storage != "" {
+		items = append(items, "STORAGE "+opClass.Storage)
+	}
+
+	return fmt.Sprintf("CREATE OPERATOR CLASS %s%s FOR TYPE %s USING %s AS %s", db.Quote(opClass.Name), defaultSql,
+		opClass.Type, opClass.Method, strings.Join(items, ", "))
+}
+
+func (db *Postgres) DropOperatorClassSql(name string, method string) string {
+	return fmt.Sprintf("DROP OPERATOR CLASS IF EXISTS %s USING %s", db.Quote(name), method)
+}
+
+// DropAggregateSql needs the argument types, aggregates can be overloaded.
+func (db *Postgres) DropAggregateSql(name string, args []string) string {
+	return fmt.Sprintf("DROP AGGREGATE IF EXISTS %s (%s)", db.quoteFunction(name), aggregateArgs(args))
+}
+
+// CreateTextSearchConfigSql uses parser, or copies copyFrom when it is empty.
+func (db *Postgres) CreateTextSearchConfigSql(name string, copyFrom string, parser string) string {
+	option := "COPY = " + copyFrom
+	if parser != "" {
+		option = "PARSER = " + parser
+	}
+
+	return fmt.Sprintf("CREATE TEXT SEARCH CONFIGURATION %s (%s)", db.Quote(name), option)
+}
+
+// AlterTextSearchMappingSql adds the mapping, or replaces it unless add is set.
+func (db *Postgres) AlterTextSearchMappingSql(name string, tokenTypes []string, dictionaries []string, add bool) string {
+	action := "ALTER"
+	if add {
+		action = "ADD"
+	}
+
+	return fmt.Sprintf("ALTER TEXT SEARCH CONFIGURATION %s %s MAPPING FOR %s WITH %s", db.Quote(name), action,
+		strings.Join(tokenTypes, ", "), strings.Join(dictionaries, ", "))
+}
+
+func (db *Postgres) DropTextSearchConfigSql(name string) string {
+	return fmt.Sprintf("DROP TEXT SEARCH CONFIGURATION IF EXISTS %s", db.Quote(name))
+}
+
+// AddEnumValueSql appends the value to the enum type.
+func (db *Postgres) AddEnumValueSql(typeName string, value string, ifNotExists bool) string {
+	var ifNotExistsSql string
+	if ifNotExists {
+		ifNotExistsSql = "IF NOT EXISTS "
+	}
+
+	return fmt.Sprintf("ALTER TYPE %s ADD VALUE %s%s", db.Quote(typeName), ifNotExistsSql, quoteLiteral(value))
+}
+
+func (db *Postgres) DropCastSql(source string, target string) string {
+	return fmt.Sprintf("DROP CAST IF EXISTS (%s AS %s)", source, target)
+}
+
+func (db *Postgres) SetReplicaIdentitySql(tableName string, identity ReplicaIdentity) string {
+	sql := fmt.Sprintf("ALTER TABLE %s REPLICA IDENTITY %s", db.Quote(tableName), identity.Mode)
+	if identity.IndexName != "" {
+		sql += " " + db.Quote(identity.IndexName)
+	}
+
+	return sql
+}
+
+func (db *Postgres) EnableRowLevelSecuritySql(tableName string, enable bool) string {
+	if enable {
+		return fmt.Sprintf("ALTER TABLE %s ENABLE ROW LEVEL SECURITY", db.Quote(tableName))
+	}
+	return fmt.Sprintf("ALTER TABLE %s DISABLE ROW LEVEL SECURITY", db.Quote(tableName))
+}
+
+// LockTableSql locks the table until the end of the transaction.
+func (db *Postgres) LockTableSql(tableName string, mode LockMode) string {
+	return fmt.Sprintf("LOCK TABLE %s IN %s MODE", db.Quote(tableName), mode)
+}
+
+// SetTableLoggedSql switches the table between logged and unlogged.
+func (db *Postgres) SetTableLoggedSql(tableName string, logged bool) string {
+	if logged {
+		return fmt.Sprintf("ALTER TABLE %s SET LOGGED", db.Quote(tableName))
+	}
+	return fmt.Sprintf("ALTER TABLE %s SET UNLOGGED", db.Quote(tableName))
+}
+
+// ClusterTableSql rewrites the table in the order of the index.
+func (db *Postgres) ClusterTableSql(tableName string, indexName string) string {
+	return fmt.Sprintf("CLUSTER %s USING %s", db.Quote(tableName), db.Quote(indexName))
+}
+
+// ForceRowLevelSecuritySql applies the policies of the table to its owner.
+func (db *Postgres) ForceRowLevelSecuritySql(tableName string, force bool) string {
+	if force {
+		return fmt.Sprintf("ALTER TABLE %s FORCE ROW LEVEL SECURITY", db.Quote(tableName))
+	}
+	return fmt.Sprintf("ALTER TABLE %s NO FORCE ROW LEVEL SECURITY", db.Quote(tableName))
+}
+
+func (db *Postgres) AlterTableOwnerSql(tableName string, owner string) string {
+	return fmt.Sprintf("ALTER TABLE %s OWNER TO %s", db.Quote(tableName), db.Quote(owner))
+}
+
+func (db *Postgres) AlterSequenceOwnerSql(sequenceName string, owner string) string {
+	return fmt.Sprintf("ALTER SEQUENCE %s OWNER TO %s", db.Quote(sequenceName), db.Quote(owner))
+}
+
+func (db *Postgres) AlterViewOwnerSql(viewName string, owner string) string {
+	return fmt.Sprintf("ALTER VIEW %s OWNER TO %s", db.Quote(viewName), db.Quote(owner))
 }
 
 func (db *Postgres) UpdateTableSql(tableName string, columns []*Column) string {
 	var statements = []string{}
 
 	for _, col := range columns {
-		statements = append(statements, "ALTER "+db.Quote(col.Name)+" TYPE "+db.SqlType(col))
+		statement := "ALTER " + db.Quote(col.Name) + " TYPE " + db.SqlType(col)
+		if col.UsingExpr != "" {
+			statement += " USING " + col.UsingExpr
+		}
+		statements = append(statements, statement)
 	}
 
-	return "ALTER TABLE " + db.Quote(tableName) + " " + strings.Join(statements, ", ") + ";"
+	return "ALTER TABLE " + db.Quote(tableName) + " " + strings.Join(statements, ", ")
+}
+
+// AlterDatabaseCharsetSql is a no-op, the encoding is fixed at creation.
+func (db *Postgres) AlterDatabaseCharsetSql(dbName string, charset string, collation string) string {
+	return db.NoOpSql()
 }
 
 func (db *Postgres) CleanDB() error {
@@ -134,11 +833,11 @@ func (db *Postgres) CleanDB() error {
 	defer sess.Close()
 
 	if _, err := sess.Exec("DROP SCHEMA public CASCADE;"); err != nil {
-		return fmt.Errorf("Failed to drop schema public")
+		return fmt.Errorf("%v: %w", "failed to drop schema public", err)
 	}
 
 	if _, err := sess.Exec("CREATE SCHEMA public;"); err != nil {
-		return fmt.Errorf("Failed to create schema public")
+		return fmt.Errorf("%v: %w", "failed to create schema public", err)
 	}
 
 	return nil
@@ -159,3 +858,26 @@ func (db *Postgres) IsUniqueConstraintViolation(err error) bool {
 func (db *Postgres) IsDeadlock(err error) bool {
 	return db.isThisError(err, "40P01")
 }
+
+// IsConnectionError reports errors after which the connection is gone.
+func (db *Postgres) IsConnectionError(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+
+	if driverErr, ok := err.(*pq.Error); ok && driverErr.Code.Class() == "08" {
+		return true
+	}
+
+	return db.IsAdminShutdown(err) ||
+		db.isThisError(err, "57P02") ||
+		db.isThisError(err, "57P03")
+}
+
+func (db *Postgres) IsAdminShutdown(err error) bool {
+	return db.isThisError(err, "57P01")
+}
+
+func (db *Postgres) IsQueryCanceled(err error) bool {
+	return db.isThisError(err, "57014")
+}
@@ -0,0 +1,405 @@
+package migrator
+
+import (
+	"database/sql/driver"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"xorm.io/xorm"
+	"xorm.io/xorm/core"
+)
+
+func TestPostgresUpdateTableSql(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewPostgresDialect(nil)
+	columns := []*Column{
+		{Name: "amount", Type: DB_Int, UsingExpr: `"amount"::integer`},
+		{Name: "note", Type: DB_Text},
+	}
+
+	sql := NewTableCharsetMigration("order", columns).SQL(d)
+	assert.Equal(`ALTER TABLE "order" ALTER "amount" TYPE INTEGER USING "amount"::integer, ALTER "note" TYPE TEXT`, sql)
+}
+
+func TestPostgresNoOpSql(t *testing.T) {
+	d := NewPostgresDialect(nil)
+
+	assert.Equal(t, "SELECT 1", d.NoOpSql())
+	// raw SQL without a statement for the dialect falls back to it
+	assert.Equal(t, "SELECT 1", NewRawSqlMigration("").Set("mysql", "ALTER TABLE user ENGINE=InnoDB").SQL(d))
+}
+
+func TestPostgresSchemaSql(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewPostgresDialect(nil)
+
+	assert.Equal(`CREATE SCHEMA IF NOT EXISTS "tenant_1"`, NewCreateSchemaMigration("tenant_1").SQL(d))
+	assert.Equal(`DROP SCHEMA IF EXISTS "tenant_1"`, NewDropSchemaMigration("tenant_1", false).SQL(d))
+	assert.Equal(`DROP SCHEMA IF EXISTS "tenant_1" CASCADE`, NewDropSchemaMigration("tenant_1", true).SQL(d))
+
+	// a schema is a database on MySQL, dropping one always cascades
+	mysql := &storageEngineDialect{Postgres: NewPostgresDialect(nil)}
+	mysql.BaseDialect.dialect = mysql
+	assert.Equal("CREATE DATABASE IF NOT EXISTS `tenant_1`", mysql.BaseDialect.CreateSchemaSql("tenant_1"))
+	assert.Equal("DROP DATABASE IF EXISTS `tenant_1`", mysql.BaseDialect.DropSchemaSql("tenant_1", false))
+	assert.Equal("DROP DATABASE IF EXISTS `tenant_1`", mysql.BaseDialect.DropSchemaSql("tenant_1", true))
+}
+
+func TestBaseDialectRendersMySql(t *testing.T) {
+	assert := assert.New(t)
+
+	mysql := &storageEngineDialect{Postgres: NewPostgresDialect(nil)}
+	mysql.BaseDialect.dialect = mysql
+
+	sql, args := mysql.BaseDialect.ColumnCheckSql("user", "email")
+	assert.Equal("SELECT 1 FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?", sql)
+	assert.Equal([]interface{}{"user", "email"}, args)
+
+	assert.Equal("SET FOREIGN_KEY_CHECKS = 0", mysql.BaseDialect.DisableTriggersSql())
+	assert.Equal("SET FOREIGN_KEY_CHECKS = 1", mysql.BaseDialect.EnableTriggersSql())
+
+	cols := []string{"key", "value"}
+	assert.Equal("INSERT INTO `setting` (`key`\n, `value`) VALUES (?, ?), (?, ?) ON DUPLICATE KEY UPDATE `value` = VALUES(`value`)",
+		mysql.BaseDialect.BulkUpsertSql("setting", cols, []string{"key"}, []string{"value"}, 2))
+	assert.Equal("INSERT IGNORE INTO `setting` (`key`\n, `value`) VALUES (?, ?)",
+		mysql.BaseDialect.BulkUpsertSql("setting", cols, []string{"key"}, nil, 1))
+}
+
+func TestPostgresErrorClassification(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewPostgresDialect(nil)
+
+	assert.True(d.IsConnectionError(&pq.Error{Code: "08006"}))
+	assert.True(d.IsConnectionError(&pq.Error{Code: "57P01"}))
+	assert.True(d.IsConnectionError(driver.ErrBadConn))
+	assert.False(d.IsConnectionError(&pq.Error{Code: "23505"}))
+
+	assert.True(d.IsAdminShutdown(&pq.Error{Code: "57P01"}))
+	assert.False(d.IsAdminShutdown(&pq.Error{Code: "57014"}))
+
+	assert.True(d.IsQueryCanceled(&pq.Error{Code: "57014"}))
+	assert.False(d.IsQueryCanceled(errors.New("canceled")))
+}
+
+func TestPostgresDropColumnSql(t *testing.T) {
+	d := NewPostgresDialect(nil)
+
+	sql := NewRemoveColumnMigration(Table{Name: "user"}, "email").SQL(d)
+	assert.Equal(t, `ALTER TABLE "user" DROP COLUMN "email"`, sql)
+}
+
+func TestPostgresDDLHasNoTrailingSemicolon(t *testing.T) {
+	d := NewPostgresDialect(nil)
+	table := Table{
+		Name: "user",
+		Columns: []*Column{
+			{Name: "id", Type: DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "login", Type: DB_NVarchar, Length: 255},
+		},
+	}
+	column := table.Columns[1]
+	index := &Index{Cols: []string{"login"}, Type: UniqueIndex}
+
+	statements := map[string]string{
+		"create table":      d.CreateTableSql(&table),
+		"create table like": d.CreateTableLikeSql(&Table{Name: "user_copy", LikeTable: "user"}),
+		"add column":        d.AddColumnSql(table.Name, column),
+		"drop column":       d.DropColumnSql(table.Name, column),
+		"update table":      d.UpdateTableSql(table.Name, []*Column{column}),
+		"rename table":      d.RenameTable("user", "account"),
+		"rename column":     d.RenameColumn(table.Name, "login", "login_name"),
+		"drop table":        d.DropTable(table.Name),
+		"create index":      d.CreateIndexSql(table.Name, index),
+		"drop index":        d.DropIndexSql(table.Name, index),
+		"copy table data":   d.CopyTableData("user", "user_copy", []string{"id"}, []string{"id"}),
+		"no-op":             d.NoOpSql(),
+	}
+
+	for name, sql := range statements {
+		assert.False(t, strings.HasSuffix(strings.TrimSpace(sql), ";"), "%s: %s", name, sql)
+	}
+}
+
+func TestPostgresIntervalType(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewPostgresDialect(nil)
+
+	assert.Equal("INTERVAL", d.SqlType(&Column{Name: "timeout", Type: DB_Interval}))
+	assert.Equal("INTERVAL(3)", d.SqlType(&Column{Name: "timeout", Type: DB_Interval, IntervalPrecision: 3}))
+}
+
+func TestPostgresIndexCheckSql(t *testing.T) {
+	d := NewPostgresDialect(nil)
+
+	sql, args := d.IndexCheckSql("user", "IDX_user_login")
+	assert.Equal(t, "SELECT 1 FROM pg_indexes WHERE tablename = ? AND indexname = ?", sql)
+	assert.Equal(t, []interface{}{"user", "IDX_user_login"}, args)
+}
+
+// TestPostgresIndexCheckSqlIntegration runs the query against the database in
+// MIGRATOR_TEST_POSTGRES_DSN, the catalog columns cannot be checked otherwise.
+func TestPostgresIndexCheckSqlIntegration(t *testing.T) {
+	dsn := os.Getenv("MIGRATOR_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("MIGRATOR_TEST_POSTGRES_DSN is not set")
+	}
+
+	engine, err := xorm.NewEngine(POSTGRES, dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { engine.Close() })
+
+	d := NewPostgresDialect(engine)
+	table := Table{
+		Name:    "migrator_index_check",
+		Columns: []*Column{{Name: "id", Type: DB_BigInt, IsPrimaryKey: true}, {Name: "login", Type: DB_Text}},
+	}
+	index := &Index{Cols: []string{"login"}}
+
+	_, err = engine.Exec(d.CreateTableSql(&table))
+	require.NoError(t, err)
+	t.Cleanup(func() { engine.Exec(d.DropTable(table.Name)) })
+
+	exists := func() bool {
+		sql, args := d.IndexCheckSql(table.Name, index.XName(table.Name))
+		results, err := engine.SQL(sql, args...).Query()
+		require.NoError(t, err)
+		return len(results) > 0
+	}
+
+	assert.False(t, exists())
+
+	_, err = engine.Exec(d.CreateIndexSql(table.Name, index))
+	require.NoError(t, err)
+	assert.True(t, exists())
+}
+
+func TestPostgresCreateIndexSqlQuotesReservedWords(t *testing.T) {
+	d := NewPostgresDialect(nil)
+
+	index := &Index{Cols: []string{"user", "order"}, Type: UniqueIndex}
+	assert.Equal(t, `CREATE UNIQUE INDEX "UQE_group_user_order" ON "group" ("user","order")`, d.CreateIndexSql("group", index))
+}
+
+func TestPostgresCreateIndexSqlOperatorClasses(t *testing.T) {
+	d := NewPostgresDialect(nil)
+
+	index := &Index{
+		Cols:            []string{"org_id", "title"},
+		OperatorClasses: map[string]string{"title": "text_pattern_ops"},
+	}
+	assert.Equal(t, `CREATE INDEX "IDX_dashboard_org_id_title" ON "dashboard" ("org_id","title" text_pattern_ops)`, d.CreateIndexSql("dashboard", index))
+	assert.Equal(t, `CREATE INDEX CONCURRENTLY "IDX_dashboard_org_id_title" ON "dashboard" ("org_id","title" text_pattern_ops)`,
+		d.CreateIndexConcurrentlySql("dashboard", index))
+}
+
+func TestPostgresLongIndexNamesAreShortened(t *testing.T) {
+	d := NewPostgresDialect(nil)
+
+	index := &Index{Cols: []string{"organization_id", "dashboard_folder_uid", "created_by_user_id"}}
+	name := index.XNameWithLimit("dashboard_version_history", d.MaxIdentifierLength())
+
+	assert.Len(t, name, 63)
+	assert.True(t, strings.HasPrefix(name, "IDX_dashboard_version_history_organization_id_dashboar_"))
+	assert.Equal(t, name, index.XNameWithLimit("dashboard_version_history", d.MaxIdentifierLength()))
+	assert.Equal(t, `CREATE INDEX "`+name+`" ON "dashboard_version_history" ("organization_id","dashboard_folder_uid","created_by_user_id")`,
+		d.CreateIndexSql("dashboard_version_history", index))
+
+	condition := &IfIndexNotExistsCondition{TableName: "dashboard_version_history", IndexName: index.XName("dashboard_version_history")}
+	_, args := condition.Sql(d)
+	assert.Equal(t, []interface{}{"dashboard_version_history", name}, args)
+
+	short := &Index{Cols: []string{"login"}}
+	assert.Equal(t, "IDX_user_login", short.XNameWithLimit("user", d.MaxIdentifierLength()))
+}
+
+func TestPostgresGeometricTypes(t *testing.T) {
+	d := NewPostgresDialect(nil)
+
+	for _, typ := range []string{DB_Point, DB_Line, DB_LSeg, DB_Box, DB_Path, DB_Polygon, DB_Circle} {
+		assert.Equal(t, typ, d.SqlType(&Column{Name: "shape", Type: typ, Length: 10}))
+	}
+}
+
+func TestPostgresDefaultNull(t *testing.T) {
+	d := NewPostgresDialect(nil)
+
+	for _, typ := range []string{DB_Bool, DB_Int, DB_Text, DB_TimeStamp} {
+		col := &Column{Name: "value", Type: typ, Nullable: true, IsDefaultNull: true}
+		assert.Equal(t, "NULL", d.Default(col), typ)
+		assert.True(t, strings.HasSuffix(col.String(d), "NULL DEFAULT NULL "), typ)
+	}
+
+	table := &Table{Name: "setting", Columns: []*Column{{Name: "enabled", Type: DB_Bool, Nullable: true, IsDefaultNull: true}}}
+	assert.Equal(t, `CREATE TABLE IF NOT EXISTS "setting" (
+"enabled" BOOL NULL DEFAULT NULL
+)`, d.CreateTableSql(table))
+}
+
+func TestPostgresCleanDBWrapsDriverError(t *testing.T) {
+	mg, mock := newTestMigrator(t)
+
+	permissionDenied := &pq.Error{Code: "42501", Message: "must be owner of schema public"}
+	mock.ExpectExec(`DROP SCHEMA public CASCADE`).WillReturnError(permissionDenied)
+
+	err := mg.Dialect.CleanDB()
+	assert.ErrorContains(t, err, "failed to drop schema public")
+	assert.True(t, errors.Is(err, permissionDenied))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresBulkUpsertSql(t *testing.T) {
+	d := NewPostgresDialect(nil)
+
+	assert.Equal(t, `INSERT INTO "user" ("login", "email", "name") VALUES (?, ?, ?) ON CONFLICT ("login") DO UPDATE SET "email" = EXCLUDED."email", "name" = EXCLUDED."name"`,
+		d.BulkUpsertSql("user", []string{"login", "email", "name"}, []string{"login"}, []string{"email", "name"}, 1))
+	assert.Equal(t, `INSERT INTO "user" ("login") VALUES (?), (?) ON CONFLICT ("login") DO NOTHING`,
+		d.BulkUpsertSql("user", []string{"login"}, []string{"login"}, nil, 2))
+}
+
+// storageEngineDialect is a Postgres dialect posing as MySQL, it claims MySQL
+// table option support and quotes like MySQL, so the MySQL flavoured
+// BaseDialect SQL can be tested.
+type storageEngineDialect struct {
+	*Postgres
+}
+
+func (d *storageEngineDialect) SupportEngine() bool {
+	return true
+}
+
+func (d *storageEngineDialect) Quote(name string) string {
+	return "`" + name + "`"
+}
+
+func (d *storageEngineDialect) AlterDatabaseCharsetSql(dbName string, charset string, collation string) string {
+	return d.BaseDialect.AlterDatabaseCharsetSql(dbName, charset, collation)
+}
+
+func (d *storageEngineDialect) LockTableSql(tableName string, mode LockMode) string {
+	return d.BaseDialect.LockTableSql(tableName, mode)
+}
+
+func (d *storageEngineDialect) TransactionalSettings() bool {
+	return d.BaseDialect.TransactionalSettings()
+}
+
+func (d *storageEngineDialect) DisableForeignKeyChecksSql() string {
+	return d.BaseDialect.DisableForeignKeyChecksSql()
+}
+
+func (d *storageEngineDialect) EnableForeignKeyChecksSql() string {
+	return d.BaseDialect.EnableForeignKeyChecksSql()
+}
+
+func TestCreateTableSqlStorageEngineOptions(t *testing.T) {
+	table := &Table{
+		Name:      "event",
+		Columns:   []*Column{{Name: "id", Type: DB_BigInt, IsPrimaryKey: true}},
+		Engine:    "MyISAM",
+		RowFormat: "COMPRESSED",
+	}
+
+	pg := NewPostgresDialect(nil)
+	sql := pg.CreateTableSql(table)
+	assert.NotContains(t, sql, "ENGINE")
+	assert.NotContains(t, sql, "ROW_FORMAT")
+
+	mysql := &storageEngineDialect{Postgres: NewPostgresDialect(nil)}
+	mysql.BaseDialect.dialect = mysql
+	assert.True(t, strings.HasSuffix(mysql.CreateTableSql(table),
+		") ENGINE=MyISAM ROW_FORMAT=COMPRESSED DEFAULT CHARSET utf8mb4 COLLATE utf8mb4_unicode_ci"))
+
+	table.Engine, table.RowFormat = "", ""
+	assert.True(t, strings.HasSuffix(mysql.CreateTableSql(table),
+		") ENGINE=InnoDB DEFAULT CHARSET utf8mb4 COLLATE utf8mb4_unicode_ci"))
+}
+
+func TestPostgresJSONBDefault(t *testing.T) {
+	d := NewPostgresDialect(nil)
+
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{value: "{}", expected: "'{}'::jsonb"},
+		{value: "'{}'", expected: "'{}'::jsonb"},
+		{value: `'{"name": "it''s"}'`, expected: `'{"name": "it''s"}'::jsonb`},
+		{value: "[]", expected: "'[]'::jsonb"},
+		{value: "'[]'::jsonb", expected: "'[]'::jsonb"},
+		{value: "jsonb_build_object()", expected: "jsonb_build_object()"},
+	}
+
+	for _, tt := range tests {
+		col := &Column{Name: "settings", Type: DB_JSONB, Default: tt.value}
+		assert.Equal(t, tt.expected, d.Default(col), tt.value)
+	}
+
+	col := &Column{Name: "settings", Type: DB_JSONB, Default: "{}"}
+	assert.Equal(t, `"settings" JSONB NOT NULL DEFAULT '{}'::jsonb `, d.ColString(col))
+}
+
+func TestTableComment(t *testing.T) {
+	table := Table{
+		Name:    "event",
+		Columns: []*Column{{Name: "id", Type: DB_BigInt, IsPrimaryKey: true}},
+		Comment: "audit events, kept for a year",
+	}
+
+	pg := NewPostgresDialect(nil)
+	assert.Equal(t, `CREATE TABLE IF NOT EXISTS "event" (
+"id" BIGINT PRIMARY KEY NOT NULL
+);
+COMMENT ON TABLE "event" IS 'audit events, kept for a year'`, NewAddTableMigration(table).SQL(pg))
+	assert.NotContains(t, pg.CreateTableSql(&table), "COMMENT")
+
+	mysql := &storageEngineDialect{Postgres: NewPostgresDialect(nil)}
+	mysql.BaseDialect.dialect = mysql
+	assert.True(t, strings.HasSuffix(mysql.CreateTableSql(&table),
+		" COLLATE utf8mb4_unicode_ci COMMENT='audit events, kept for a year'"))
+}
+
+func TestAlterDatabaseCharsetMigration(t *testing.T) {
+	m := NewAlterDatabaseCharsetMigration("app", "utf8mb4", "utf8mb4_unicode_ci")
+
+	pg := NewPostgresDialect(nil)
+	assert.Equal(t, pg.NoOpSql(), m.SQL(pg))
+
+	mysql := &storageEngineDialect{Postgres: NewPostgresDialect(nil)}
+	mysql.BaseDialect.dialect = mysql
+	assert.Equal(t, "ALTER DATABASE `app` CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci", m.SQL(mysql))
+
+	columns := []*Column{{Name: "note", Type: DB_Text}}
+	tables := NewTableCharsetMigration("order", columns).WithDatabaseCharset("app", "utf8mb4", "utf8mb4_unicode_ci")
+	assert.Equal(t, "ALTER DATABASE `app` CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;\n"+`ALTER TABLE "order" ALTER "note" TYPE TEXT`,
+		tables.SQL(mysql))
+	assert.Equal(t, `ALTER TABLE "order" ALTER "note" TYPE TEXT`, tables.SQL(pg))
+}
+
+func TestDialectForEngine(t *testing.T) {
+	newEngine := func(driverName string, dsn string) *xorm.Engine {
+		db, _, err := sqlmock.New()
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+
+		engine, err := xorm.NewEngineWithDB(driverName, dsn, core.FromDB(db))
+		require.NoError(t, err)
+		return engine
+	}
+
+	d, err := DialectForEngine(newEngine(POSTGRES, "postgres://localhost:5432/test?sslmode=disable"))
+	require.NoError(t, err)
+	assert.IsType(t, &Postgres{}, d)
+
+	_, err = DialectForEngine(newEngine(MYSQL, "root@tcp(localhost:3306)/test"))
+	assert.EqualError(t, err, "unsupported database type: mysql")
+}
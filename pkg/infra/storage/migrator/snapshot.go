@@ -0,0 +1,93 @@
+package migrator
+
+import (
+	"xorm.io/xorm"
+)
+
+type schemaSnapshot struct {
+	searchPath string
+	tables     map[string]struct{}
+	columns    map[[2]string]struct{}
+	indexes    map[[2]string]struct{}
+}
+
+func loadSchemaSnapshot(d Dialect, sess *xorm.Session) (*schemaSnapshot, error) {
+	s := &schemaSnapshot{
+		tables:  make(map[string]struct{}),
+		columns: make(map[[2]string]struct{}),
+		indexes: make(map[[2]string]struct{}),
+	}
+
+	query := func(sql string, args []interface{}, add func(row map[string][]byte)) error {
+		rows, err := sess.SQL(sql, args...).Query()
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			add(row)
+		}
+		return nil
+	}
+
+	sql, args := d.TablesSql()
+	if err := query(sql, args, func(row map[string][]byte) {
+		s.tables[string(row["tablename"])] = struct{}{}
+	}); err != nil {
+		return nil, err
+	}
+
+	sql, args = d.ColumnsSql()
+	if err := query(sql, args, func(row map[string][]byte) {
+		s.columns[[2]string{string(row["tablename"]), string(row["columnname"])}] = struct{}{}
+	}); err != nil {
+		return nil, err
+	}
+
+	sql, args = d.IndexesSql()
+	if err := query(sql, args, func(row map[string][]byte) {
+		s.indexes[[2]string{string(row["tablename"]), string(row["indexname"])}] = struct{}{}
+	}); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *schemaSnapshot) evaluate(condition MigrationCondition, d Dialect) (fulfilled bool, ok bool) {
+	hasTable := func(table string) bool {
+		_, exists := s.tables[table]
+		return exists
+	}
+	hasColumn := func(table, column string) bool {
+		_, exists := s.columns[[2]string{table, column}]
+		return exists
+	}
+	hasIndex := func(table, index string) bool {
+		_, exists := s.indexes[[2]string{table, shortenIdentifier(index, d.MaxIdentifierLength())}]
+		return exists
+	}
+
+	switch c := condition.(type) {
+	case *IfTableExistsCondition:
+		return hasTable(c.TableName), true
+	case *IfTableNotExistsCondition:
+		return !hasTable(c.TableName), true
+	case *IfColumnNotExistsCondition:
+		return !hasColumn(c.TableName, c.ColumnName), true
+	case *IfIndexExistsCondition:
+		return hasIndex(c.TableName, c.IndexName), true
+	case *IfIndexNotExistsCondition:
+		return !hasIndex(c.TableName, c.IndexName), true
+	case *AndCondition:
+		for _, condition := range c.Conditions {
+			fulfilled, ok := s.evaluate(condition, d)
+			if !ok || !fulfilled {
+				return fulfilled, ok
+			}
+		}
+		return true, true
+	}
+
+	return false, false
+}
@@ -0,0 +1,106 @@
+package migrator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// MigrationStatus tells whether a registered migration has been applied.
+type MigrationStatus struct {
+	MigrationID string
+	Applied     bool
+	// AppliedAt is when the migration was recorded in the migration log.
+	AppliedAt time.Time
+}
+
+// Status reports the registered migrations and whether they are applied.
+func (mg *Migrator) Status() ([]MigrationStatus, error) {
+	logMap, err := mg.GetMigrationLog()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(mg.migrations))
+	for _, m := range mg.migrations {
+		status := MigrationStatus{MigrationID: m.Id()}
+		if logItem, exists := logMap[m.Id()]; exists {
+			status.Applied = true
+			status.AppliedAt = logItem.Timestamp
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// AppliedAt returns when the migration was recorded in the migration log.
+func (mg *Migrator) AppliedAt(id string) (time.Time, bool, error) {
+	logMap, err := mg.GetMigrationLog()
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	logItem, exists := logMap[id]
+	if !exists {
+		return time.Time{}, false, nil
+	}
+
+	return logItem.Timestamp, true, nil
+}
+
+// ListPending returns the registered migrations missing from the migration log.
+func (mg *Migrator) ListPending(ctx context.Context) ([]Migration, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	logMap, err := mg.GetMigrationLog()
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make([]Migration, 0)
+	for _, m := range mg.migrations {
+		if _, exists := logMap[m.Id()]; !exists {
+			pending = append(pending, m)
+		}
+	}
+
+	return pending, nil
+}
+
+// MonitorMigrations passes the migration status to fn every interval until ctx is done.
+func (mg *Migrator) MonitorMigrations(ctx context.Context, interval time.Duration, fn func([]MigrationStatus)) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid monitoring interval: %v", interval)
+	}
+
+	poll := func() {
+		statuses, err := mg.Status()
+		if err != nil {
+			mg.log.Warn("reading migration status failed", zap.Error(err))
+			return
+		}
+		fn(statuses)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		poll()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				poll()
+			}
+		}
+	}()
+
+	return nil
+}
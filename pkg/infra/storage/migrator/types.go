@@ -1,14 +1,20 @@
 package migrator
 
 import (
+	"database/sql"
 	"fmt"
+	"hash/fnv"
 	"strings"
+	"time"
 
 	"xorm.io/xorm"
 )
 
 const (
 	POSTGRES = "postgres"
+	MYSQL    = "mysql"
+	SQLITE   = "sqlite3"
+	MSSQL    = "mssql"
 )
 
 type Migration interface {
@@ -23,6 +29,84 @@ type CodeMigration interface {
 	Exec(sess *xorm.Session, migrator *Migrator) error
 }
 
+// ResumableMigration runs in steps that are committed one by one.
+type ResumableMigration interface {
+	Migration
+	Resumable() bool
+	// ExecStep runs the step after checkpoint and returns the new one, empty when done.
+	ExecStep(sess *xorm.Session, migrator *Migrator, checkpoint string) (string, int64, error)
+}
+
+// NonTransactionalMigration runs outside of a transaction when NonTransactional is true.
+type NonTransactionalMigration interface {
+	Migration
+	NonTransactional() bool
+}
+
+// DialectNonTransactionalMigration runs outside of a transaction for some servers.
+type DialectNonTransactionalMigration interface {
+	Migration
+	NonTransactionalFor(dialect Dialect) bool
+}
+
+type hookedMigration interface {
+	locksTables() bool
+	beforeSql(dialect Dialect) []string
+	afterSql(dialect Dialect) []string
+	restoreSql(dialect Dialect) []string
+}
+
+// ValidatingMigration is validated before any pending migration is executed.
+type ValidatingMigration interface {
+	Migration
+	Validate() error
+}
+
+// DialectValidatingMigration is validated against the dialect of the migrator.
+type DialectValidatingMigration interface {
+	Migration
+	ValidateDialect(dialect Dialect) error
+}
+
+// DestructiveMigration loses data when Destructive reports true.
+type DestructiveMigration interface {
+	Migration
+	Destructive() bool
+}
+
+func isDestructive(m Migration) bool {
+	dm, ok := m.(DestructiveMigration)
+	return ok && dm.Destructive()
+}
+
+// ReversibleMigration can be rolled back with the SQL returned by DownSQL.
+type ReversibleMigration interface {
+	Migration
+	DownSQL(dialect Dialect) (string, error)
+}
+
+type verifiedMigration interface {
+	verify(sess *xorm.Session, dialect Dialect, res sql.Result) error
+}
+
+type checkedMigration interface {
+	check(sess *xorm.Session, dialect Dialect) error
+}
+
+type scheduledMigration interface {
+	due(sess *xorm.Session, mg *Migrator) (bool, error)
+}
+
+type timedMigration interface {
+	setRunTime(t time.Time)
+}
+
+// SensitiveMigration has secrets in its SQL, only the redacted SQL is logged.
+type SensitiveMigration interface {
+	Migration
+	RedactedSQL(dialect Dialect) string
+}
+
 type SQLType string
 
 type ColumnType string
@@ -32,12 +116,26 @@ const (
 )
 
 type Table struct {
-	Name        string
-	Columns     []*Column
-	PrimaryKeys []string
-	Uniques     []string
-	Indices     []*Index
-	Schema      string
+	Name             string
+	Columns          []*Column
+	PrimaryKeys      []string
+	Uniques          []string
+	Indices          []*Index
+	Schema           string
+	LikeTable        string
+	ForeignKeys      []ForeignKey
+	CheckConstraints []CheckConstraint
+	// StorageParams are the Postgres storage parameters of the table.
+	StorageParams map[string]string
+	// RowSecurityEnabled enables row level security on the new table.
+	RowSecurityEnabled bool
+	// Unlogged creates a Postgres table that skips the write-ahead log.
+	Unlogged bool
+	// Comment documents the table in the database.
+	Comment string
+	// Engine and RowFormat are MySQL table options.
+	Engine    string
+	RowFormat string
 }
 
 const (
@@ -49,6 +147,8 @@ type Index struct {
 	Name string
 	Type int
 	Cols []string
+	// OperatorClasses maps columns to their Postgres operator class.
+	OperatorClasses map[string]string
 }
 
 func (index *Index) XName(tableName string) string {
@@ -66,6 +166,189 @@ func (index *Index) XName(tableName string) string {
 	return index.Name
 }
 
+// XNameWithLimit returns XName shortened to at most maxLength bytes.
+func (index *Index) XNameWithLimit(tableName string, maxLength int) string {
+	return shortenIdentifier(index.XName(tableName), maxLength)
+}
+
+func shortenIdentifier(name string, maxLength int) string {
+	if len(name) <= maxLength {
+		return name
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	suffix := fmt.Sprintf("_%08x", h.Sum32())
+	return name[:maxLength-len(suffix)] + suffix
+}
+
+const (
+	ForeignKeyNoAction   = "NO ACTION"
+	ForeignKeyRestrict   = "RESTRICT"
+	ForeignKeyCascade    = "CASCADE"
+	ForeignKeySetNull    = "SET NULL"
+	ForeignKeySetDefault = "SET DEFAULT"
+)
+
+type ForeignKey struct {
+	Name     string
+	Cols     []string
+	RefTable string
+	RefCols  []string
+	OnDelete string
+	OnUpdate string
+}
+
+func (fk *ForeignKey) XName(tableName string) string {
+	if fk.Name != "" {
+		return fk.Name
+	}
+
+	return fmt.Sprintf("FK_%v_%v", tableName, strings.Join(fk.Cols, "_"))
+}
+
+// String returns the constraint clause of the foreign key.
+func (fk *ForeignKey) String(tableName string, d Dialect) string {
+	quoteCols := func(cols []string) string {
+		quoted := make([]string, 0, len(cols))
+		for _, col := range cols {
+			quoted = append(quoted, d.Quote(col))
+		}
+		return strings.Join(quoted, ", ")
+	}
+
+	sql := fmt.Sprintf("CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s)",
+		d.Quote(fk.XName(tableName)), quoteCols(fk.Cols), d.Quote(fk.RefTable), quoteCols(fk.RefCols))
+
+	if fk.OnDelete != "" {
+		sql += " ON DELETE " + fk.OnDelete
+	}
+
+	if fk.OnUpdate != "" {
+		sql += " ON UPDATE " + fk.OnUpdate
+	}
+
+	return sql
+}
+
+type Role struct {
+	Name     string
+	Login    bool
+	Password string
+	InRoles  []string
+}
+
+const (
+	EventDDLCommandStart = "ddl_command_start"
+	EventDDLCommandEnd   = "ddl_command_end"
+	EventSQLDrop         = "sql_drop"
+	EventTableRewrite    = "table_rewrite"
+)
+
+// EventTrigger calls Function on DDL events, limited to Tags if given.
+type EventTrigger struct {
+	Name     string
+	Event    string
+	Tags     []string
+	Function string
+}
+
+// Contexts a Postgres cast is applied in.
+const (
+	CastExplicit   = "EXPLICIT"
+	CastAssignment = "ASSIGNMENT"
+	CastImplicit   = "IMPLICIT"
+)
+
+// Cast converts Source to Target by calling Function with the source value.
+type Cast struct {
+	Source   string
+	Target   string
+	Function string
+	Context  string
+}
+
+// Aggregate is a user-defined Postgres aggregate.
+type Aggregate struct {
+	Name      string
+	Args      []string
+	SFunc     string
+	SType     string
+	FinalFunc string
+	InitCond  string
+	OrReplace bool
+}
+
+// Operator is a user-defined Postgres operator, LeftArg is empty for prefix ones.
+type Operator struct {
+	Name       string
+	LeftArg    string
+	RightArg   string
+	Function   string
+	Commutator string
+	Negator    string
+}
+
+// OperatorClassMember is an operator or a support function of an operator class.
+type OperatorClassMember struct {
+	Number   int
+	Operator string
+	Function string
+}
+
+// OperatorClass tells an index method how to index values of Type.
+type OperatorClass struct {
+	Name    string
+	Type    string
+	Method  string
+	Default bool
+	Members []OperatorClassMember
+	// Storage is the type stored in the index when it differs from Type.
+	Storage string
+}
+
+// LockMode is a Postgres table lock mode, from the weakest to the strongest.
+type LockMode string
+
+const (
+	LockAccessShare          LockMode = "ACCESS SHARE"
+	LockRowShare             LockMode = "ROW SHARE"
+	LockRowExclusive         LockMode = "ROW EXCLUSIVE"
+	LockShareUpdateExclusive LockMode = "SHARE UPDATE EXCLUSIVE"
+	LockShare                LockMode = "SHARE"
+	LockShareRowExclusive    LockMode = "SHARE ROW EXCLUSIVE"
+	LockExclusive            LockMode = "EXCLUSIVE"
+	LockAccessExclusive      LockMode = "ACCESS EXCLUSIVE"
+)
+
+var lockModes = []LockMode{
+	LockAccessShare, LockRowShare, LockRowExclusive, LockShareUpdateExclusive,
+	LockShare, LockShareRowExclusive, LockExclusive, LockAccessExclusive,
+}
+
+// ReplicaIdentity selects the columns logical replication logs for changed rows.
+type ReplicaIdentity struct {
+	Mode      string
+	IndexName string
+}
+
+var (
+	ReplicaIdentityDefault = ReplicaIdentity{Mode: "DEFAULT"}
+	ReplicaIdentityFull    = ReplicaIdentity{Mode: "FULL"}
+	ReplicaIdentityNothing = ReplicaIdentity{Mode: "NOTHING"}
+)
+
+// ReplicaIdentityUsingIndex identifies rows by the columns of a unique index.
+func ReplicaIdentityUsingIndex(indexName string) ReplicaIdentity {
+	return ReplicaIdentity{Mode: "USING INDEX", IndexName: indexName}
+}
+
+// CheckConstraint is a CHECK constraint of a table.
+type CheckConstraint struct {
+	Name string
+	Expr string
+}
+
 var (
 	DB_Bit       = "BIT"
 	DB_TinyInt   = "TINYINT"
@@ -94,6 +377,8 @@ var (
 	DB_TimeStamp      = "TIMESTAMP"
 	DB_TimeStampz     = "TIMESTAMPZ"
 	DB_NowTimeZoneUTC = "(now() at time zone 'utc')"
+	// DB_Interval stores durations, BIGINT microseconds where there is no interval type.
+	DB_Interval = "INTERVAL"
 
 	DB_Decimal = "DECIMAL"
 	DB_Numeric = "NUMERIC"
@@ -115,5 +400,15 @@ var (
 	DB_Serial    = "SERIAL"
 	DB_BigSerial = "BIGSERIAL"
 
-	DB_JSON = "JSON"
+	DB_JSON  = "JSON"
+	DB_JSONB = "JSONB"
+
+	// Geometric types of Postgres, TEXT on other dialects.
+	DB_Point   = "POINT"
+	DB_Line    = "LINE"
+	DB_LSeg    = "LSEG"
+	DB_Box     = "BOX"
+	DB_Path    = "PATH"
+	DB_Polygon = "POLYGON"
+	DB_Circle  = "CIRCLE"
 )
@@ -0,0 +1,77 @@
+package migrator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// VerificationError lists the applied migrations whose SQL changed since they ran.
+type VerificationError struct {
+	MigrationIDs []string
+}
+
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("applied migrations changed since they ran: %s", strings.Join(e.MigrationIDs, ", "))
+}
+
+type volatileSqlMigration interface {
+	volatileSql() bool
+}
+
+type legacySqlMigration interface {
+	legacySql(dialect Dialect) string
+}
+
+const legacyNoOpSql = "SELECT 0"
+
+// Verify checks that the applied migrations still render the SQL they recorded.
+func (mg *Migrator) Verify(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	logMap, err := mg.GetMigrationLog()
+	if err != nil {
+		return err
+	}
+
+	var changed []string
+	for _, m := range mg.migrations {
+		logItem, exists := logMap[m.Id()]
+		if !exists {
+			continue
+		}
+
+		if vm, ok := m.(volatileSqlMigration); ok && vm.volatileSql() {
+			continue
+		}
+
+		logged := normalizeSql(logItem.SQL, mg.Dialect)
+		if logged == normalizeSql(loggableSql(m, mg.Dialect), mg.Dialect) {
+			continue
+		}
+		if lm, ok := m.(legacySqlMigration); ok && logged == normalizeSql(lm.legacySql(mg.Dialect), mg.Dialect) {
+			continue
+		}
+
+		changed = append(changed, m.Id())
+	}
+
+	if len(changed) > 0 {
+		return &VerificationError{MigrationIDs: changed}
+	}
+
+	return nil
+}
+
+func normalizeSql(sql string, d Dialect) string {
+	sql = strings.Join(strings.Fields(sql), " ")
+	sql = strings.ReplaceAll(strings.ReplaceAll(sql, " ;", ";"), "; ", ";")
+	sql = strings.TrimSpace(strings.TrimSuffix(sql, ";"))
+
+	if sql == legacyNoOpSql || sql == d.NoOpSql() {
+		return ""
+	}
+	return sql
+}